@@ -0,0 +1,303 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package v1beta1 contains the unified FederatedObject / ClusterFederatedObject
+// API: a single custom resource carrying a propagation template together
+// with its placement and per-cluster overrides, in place of a
+// FederatedTypeConfig plus one type-specific custom resource per propagated
+// Kind. The template's own apiVersion/kind is resolved via a RESTMapper at
+// dispatch time (see dispatch.NewUnifiedDispatcher) instead of being
+// declared out-of-band.
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// FederatedObject is the namespaced unified federation CR: it carries the
+// template, placement and overrides for a single namespaced target
+// resource.
+type FederatedObject struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   FederatedObjectSpec   `json:"spec,omitempty"`
+	Status FederatedObjectStatus `json:"status,omitempty"`
+}
+
+// DeepCopyObject implements runtime.Object.
+func (in *FederatedObject) DeepCopyObject() runtime.Object {
+	if in == nil {
+		return nil
+	}
+	out := new(FederatedObject)
+	out.TypeMeta = in.TypeMeta
+	out.ObjectMeta = *in.ObjectMeta.DeepCopy()
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+	return out
+}
+
+// FederatedObjectList is a list of FederatedObject.
+type FederatedObjectList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []FederatedObject `json:"items"`
+}
+
+// DeepCopyObject implements runtime.Object.
+func (in *FederatedObjectList) DeepCopyObject() runtime.Object {
+	if in == nil {
+		return nil
+	}
+	out := new(FederatedObjectList)
+	out.TypeMeta = in.TypeMeta
+	out.ListMeta = in.ListMeta
+	if in.Items != nil {
+		out.Items = make([]FederatedObject, len(in.Items))
+		for i := range in.Items {
+			in.Items[i].Spec.DeepCopyInto(&out.Items[i].Spec)
+			in.Items[i].Status.DeepCopyInto(&out.Items[i].Status)
+			out.Items[i].TypeMeta = in.Items[i].TypeMeta
+			out.Items[i].ObjectMeta = *in.Items[i].ObjectMeta.DeepCopy()
+		}
+	}
+	return out
+}
+
+// ClusterFederatedObject is the cluster-scoped counterpart of
+// FederatedObject, used when the target resource itself is cluster-scoped.
+type ClusterFederatedObject struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   FederatedObjectSpec   `json:"spec,omitempty"`
+	Status FederatedObjectStatus `json:"status,omitempty"`
+}
+
+// DeepCopyObject implements runtime.Object.
+func (in *ClusterFederatedObject) DeepCopyObject() runtime.Object {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterFederatedObject)
+	out.TypeMeta = in.TypeMeta
+	out.ObjectMeta = *in.ObjectMeta.DeepCopy()
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+	return out
+}
+
+// ClusterFederatedObjectList is a list of ClusterFederatedObject.
+type ClusterFederatedObjectList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []ClusterFederatedObject `json:"items"`
+}
+
+// DeepCopyObject implements runtime.Object.
+func (in *ClusterFederatedObjectList) DeepCopyObject() runtime.Object {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterFederatedObjectList)
+	out.TypeMeta = in.TypeMeta
+	out.ListMeta = in.ListMeta
+	if in.Items != nil {
+		out.Items = make([]ClusterFederatedObject, len(in.Items))
+		for i := range in.Items {
+			in.Items[i].Spec.DeepCopyInto(&out.Items[i].Spec)
+			in.Items[i].Status.DeepCopyInto(&out.Items[i].Status)
+			out.Items[i].TypeMeta = in.Items[i].TypeMeta
+			out.Items[i].ObjectMeta = *in.Items[i].ObjectMeta.DeepCopy()
+		}
+	}
+	return out
+}
+
+// FederatedObjectSpec holds the template to propagate along with its
+// placement and per-cluster overrides.
+type FederatedObjectSpec struct {
+	// Template is the full serialized target resource to propagate,
+	// including its own apiVersion and kind.
+	Template runtime.RawExtension `json:"template"`
+
+	// Placement lists the clusters Template should be propagated to.
+	Placement PlacementSpec `json:"placement,omitempty"`
+
+	// Overrides carries the per-cluster field overrides applied to
+	// Template before it is created or updated in each placed cluster.
+	Overrides []ClusterOverride `json:"overrides,omitempty"`
+
+	// PropagationPolicy controls how member cluster copies of Template are
+	// handled when the FederatedObject itself is deleted. Defaults to an
+	// empty PropagationPolicy, i.e. a non-orphaning, background-cascading
+	// delete.
+	PropagationPolicy PropagationPolicy `json:"propagationPolicy,omitempty"`
+}
+
+// DeepCopyInto copies in into out.
+func (in *FederatedObjectSpec) DeepCopyInto(out *FederatedObjectSpec) {
+	*out = *in
+	in.Template.DeepCopyInto(&out.Template)
+	if in.Placement.Clusters != nil {
+		out.Placement.Clusters = make([]ClusterReference, len(in.Placement.Clusters))
+		for i := range in.Placement.Clusters {
+			out.Placement.Clusters[i] = in.Placement.Clusters[i]
+			if in.Placement.Clusters[i].MinReplicas != nil {
+				out.Placement.Clusters[i].MinReplicas = new(int64)
+				*out.Placement.Clusters[i].MinReplicas = *in.Placement.Clusters[i].MinReplicas
+			}
+			if in.Placement.Clusters[i].MaxReplicas != nil {
+				out.Placement.Clusters[i].MaxReplicas = new(int64)
+				*out.Placement.Clusters[i].MaxReplicas = *in.Placement.Clusters[i].MaxReplicas
+			}
+		}
+	}
+	if in.Overrides != nil {
+		out.Overrides = make([]ClusterOverride, len(in.Overrides))
+		for i := range in.Overrides {
+			out.Overrides[i] = in.Overrides[i]
+			in.Overrides[i].Patches.DeepCopyInto(&out.Overrides[i].Patches)
+			if in.Overrides[i].Replicas != nil {
+				out.Overrides[i].Replicas = new(int64)
+				*out.Overrides[i].Replicas = *in.Overrides[i].Replicas
+			}
+		}
+	}
+	out.PropagationPolicy = in.PropagationPolicy
+}
+
+// PropagationPolicy controls cascading deletion behavior for a
+// FederatedObject's member cluster copies. It is consumed by
+// deletionhelper.NewDeletionHelperForFederatedObject to build the
+// deletionhelper.DeletionHelper that drives FinalizerCascadingDelete.
+type PropagationPolicy struct {
+	// OrphanDependents, if true, strips the managed label from every
+	// member cluster copy instead of deleting it when the FederatedObject
+	// is removed, leaving the copies under the member cluster's own
+	// management. Defaults to false.
+	OrphanDependents bool `json:"orphanDependents,omitempty"`
+
+	// DeletionPolicy governs how a member cluster's garbage collector
+	// treats a non-orphaned copy's dependents (e.g. a Deployment's
+	// ReplicaSets and Pods). Defaults to DeletionPolicyBackground. Ignored
+	// when OrphanDependents is true.
+	DeletionPolicy DeletionPolicy `json:"deletionPolicy,omitempty"`
+}
+
+// DeletionPolicy enumerates how a member cluster's garbage collector should
+// treat a deleted object's dependents.
+type DeletionPolicy string
+
+const (
+	// DeletionPolicyBackground deletes the object immediately and lets the
+	// member cluster's garbage collector remove dependents in the
+	// background.
+	DeletionPolicyBackground DeletionPolicy = "Background"
+	// DeletionPolicyForeground blocks removal of the object until its
+	// dependents have been deleted by the member cluster's garbage
+	// collector.
+	DeletionPolicyForeground DeletionPolicy = "Foreground"
+)
+
+// PlacementSpec names the clusters a FederatedObject's template should be
+// propagated to.
+type PlacementSpec struct {
+	Clusters []ClusterReference `json:"clusters,omitempty"`
+}
+
+// ClusterReference names a single FederatedCluster and, optionally, bounds
+// the replica count a controller such as automigration may assign to it via
+// a ClusterOverride.
+type ClusterReference struct {
+	Name string `json:"name"`
+
+	// MinReplicas is the fewest replicas this cluster must keep. A
+	// controller adjusting ClusterOverride.Replicas must not take a
+	// cluster below this count. Unset means no lower bound.
+	MinReplicas *int64 `json:"minReplicas,omitempty"`
+
+	// MaxReplicas is the most replicas this cluster may run. A controller
+	// adjusting ClusterOverride.Replicas must not take a cluster above
+	// this count. Unset means no upper bound.
+	MaxReplicas *int64 `json:"maxReplicas,omitempty"`
+}
+
+// ClusterOverride carries the override patch for a single cluster.
+type ClusterOverride struct {
+	ClusterName string               `json:"clusterName"`
+	Patches     runtime.RawExtension `json:"patches,omitempty"`
+
+	// Replicas overrides the replica count Template declares for this
+	// cluster, subject to the MinReplicas/MaxReplicas bounds the matching
+	// ClusterReference declares. Controllers such as automigration persist
+	// their migration decisions here rather than rewriting Patches.
+	Replicas *int64 `json:"replicas,omitempty"`
+}
+
+// FederatedObjectStatus reports, per placed cluster, the outcome of
+// propagating Template and the collected status of the resulting object.
+// It replaces the separate per-type *Status CRs with a single status
+// consolidated onto the FederatedObject itself.
+type FederatedObjectStatus struct {
+	CollectedStatus `json:",inline"`
+}
+
+// DeepCopyInto copies in into out.
+func (in *FederatedObjectStatus) DeepCopyInto(out *FederatedObjectStatus) {
+	in.CollectedStatus.DeepCopyInto(&out.CollectedStatus)
+}
+
+// CollectedStatus aggregates ClusterCollectedStatus across every cluster a
+// FederatedObject or ClusterFederatedObject was placed in.
+type CollectedStatus struct {
+	Clusters []ClusterCollectedStatus `json:"clusters,omitempty"`
+}
+
+// DeepCopyInto copies in into out.
+func (in *CollectedStatus) DeepCopyInto(out *CollectedStatus) {
+	if in.Clusters == nil {
+		return
+	}
+	out.Clusters = make([]ClusterCollectedStatus, len(in.Clusters))
+	for i := range in.Clusters {
+		out.Clusters[i] = in.Clusters[i]
+		if in.Clusters[i].Status != nil {
+			out.Clusters[i].Status = in.Clusters[i].Status.DeepCopy()
+		}
+	}
+}
+
+// ClusterCollectedStatus is a single cluster's entry in CollectedStatus:
+// whether propagation succeeded there, and a snapshot of the object's own
+// status subresource in that cluster.
+type ClusterCollectedStatus struct {
+	ClusterName string `json:"clusterName"`
+
+	// Error records the last error, if any, encountered while reconciling
+	// Template in this cluster. Cascading deletion failures from
+	// deletionhelper.DeletionHelper are not recorded here; its dispatcher
+	// reports them through runtime.HandleError.
+	Error string `json:"error,omitempty"`
+
+	// Status is the raw status subresource last observed for the object in
+	// this cluster, or nil if the target kind has none.
+	Status *runtime.RawExtension `json:"status,omitempty"`
+}
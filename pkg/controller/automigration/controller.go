@@ -0,0 +1,419 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package automigration reschedules the replicas of opted-in FederatedObjects
+// away from member clusters that can no longer run them. It reacts to two
+// signals: the util.FederatedInformer's ClusterUnavailable lifecycle hook,
+// fired when a cluster is removed or goes not-ready, and a periodic scan for
+// replicas that have sat Pending/Unschedulable in a cluster for longer than
+// their configured threshold. A FederatedObject only participates once it
+// carries AutoMigrationAnnotation; migration decisions are persisted back as
+// ClusterOverride.Replicas, bounded by each placed cluster's
+// MinReplicas/MaxReplicas.
+package automigration
+
+import (
+	"time"
+
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/util/retry"
+	"k8s.io/klog"
+
+	fedv1a1 "github.com/kubernetes-sigs/federation-v2/pkg/apis/core/v1alpha1"
+	fedv1b1 "sigs.k8s.io/kubefed/pkg/apis/core/v1beta1"
+	"sigs.k8s.io/kubefed/pkg/controller/util"
+)
+
+const (
+	// ControllerName is this controller's entry in knownControllers,
+	// allowing it to be started by name alongside the rest of the
+	// controllers in pkg/controller.
+	ControllerName = "automigration"
+
+	// AutoMigrationAnnotation opts a FederatedObject into automatic replica
+	// migration away from clusters that become unschedulable or
+	// unavailable. Its value is ignored; only presence is checked.
+	AutoMigrationAnnotation = "automigration.kubefed.io/enabled"
+
+	// UnschedulableThresholdAnnotation overrides, for a single
+	// FederatedObject, the duration a replica must remain Pending with
+	// PodScheduled=False/Unschedulable before it is considered for
+	// migration. The value must be parseable by time.ParseDuration.
+	UnschedulableThresholdAnnotation = "automigration.kubefed.io/unschedulable-threshold"
+
+	// KeepUnschedulableAnnotation overrides, for a single FederatedObject,
+	// how many unschedulable replicas are left behind in a cluster rather
+	// than migrated. This avoids flapping replicas back into a cluster
+	// that is in the process of regaining capacity. The value must be
+	// parseable by strconv.ParseInt.
+	KeepUnschedulableAnnotation = "automigration.kubefed.io/keep-unschedulable-replicas"
+
+	defaultUnschedulableThreshold = 60 * time.Second
+	defaultKeepUnschedulable      = int64(0)
+
+	schedulePeriod = 30 * time.Second
+)
+
+// knownControllers maps ControllerName to the function that starts it,
+// mirroring the registry a cmd/controller-manager would index to enable or
+// disable controllers by name. automigration has no such manager in this
+// tree yet, so it is kept local to the package as the documented
+// integration point for when one is added.
+var knownControllers = map[string]func(FederatedObjectClient, util.FederatedInformer, <-chan struct{}) *Controller{
+	ControllerName: StartController,
+}
+
+// StartController builds a Controller wired to client and informer, starts
+// its periodic scan in a goroutine, and returns it. The caller remains
+// responsible for passing the Controller's ClusterUnavailable method as the
+// util.ClusterLifecycleHandlerFuncs.ClusterUnavailable hook of informer at
+// construction time, since util.NewFederatedInformer takes that hook
+// up front.
+func StartController(client FederatedObjectClient, informer util.FederatedInformer, stopChan <-chan struct{}) *Controller {
+	controller := NewController(client, informer)
+	go controller.Run(stopChan)
+	return controller
+}
+
+// FederatedObjectClient is the subset of a generated FederatedObject client
+// automigration needs to discover opted-in objects and persist migration
+// decisions. It is satisfied by the generated clientset's
+// FederatedObjects(namespace) once one exists in this tree; tests satisfy it
+// with a fake.
+type FederatedObjectClient interface {
+	List() ([]*fedv1b1.FederatedObject, error)
+	Get(namespace, name string) (*fedv1b1.FederatedObject, error)
+	Update(fedObject *fedv1b1.FederatedObject) (*fedv1b1.FederatedObject, error)
+}
+
+// MigrationPlan describes a single replica move computed by the controller:
+// decrement From's ClusterOverride.Replicas by Replicas and increment To's
+// by the same amount. Plans are clamped to each cluster's
+// MinReplicas/MaxReplicas before being applied; see applyPlans.
+type MigrationPlan struct {
+	From     string
+	To       string
+	Replicas int64
+}
+
+// Controller reschedules replicas away from clusters that ClusterUnavailable
+// reports as failed or not-ready, and away from clusters where replicas have
+// sat Pending and unschedulable for longer than their threshold, for every
+// FederatedObject carrying AutoMigrationAnnotation.
+//
+// A Controller is wired up by passing its ClusterUnavailable method as the
+// ClusterLifecycleHandlerFuncs.ClusterUnavailable hook of the
+// util.FederatedInformer used to sync Pods, and by calling Run once the
+// informer has started (see StartController).
+type Controller struct {
+	client   FederatedObjectClient
+	informer util.FederatedInformer
+
+	// lastKnownState records, per cluster, the opted-in FederatedObjects
+	// that ClusterUnavailable found placed there (by re-listing
+	// FederatedObjects and matching placement and overrides) the last time
+	// it fired for that cluster, i.e. the objects it planned evacuations for.
+	lastKnownState map[string][]*fedv1b1.FederatedObject
+}
+
+// NewController returns an automigration Controller that discovers
+// FederatedObjects through client and inspects Pod placement through
+// informer, which must have been built over the Pods resource (see
+// util.NewFederatedInformer and util.FederatedInformer.GetPodStore).
+func NewController(client FederatedObjectClient, informer util.FederatedInformer) *Controller {
+	return &Controller{
+		client:         client,
+		informer:       informer,
+		lastKnownState: make(map[string][]*fedv1b1.FederatedObject),
+	}
+}
+
+// ClusterUnavailable records, then immediately migrates away from, every
+// opted-in FederatedObject placed in cluster, satisfying the
+// util.ClusterLifecycleHandlerFuncs.ClusterUnavailable signature. Unlike
+// schedulePendingMigrations's Pending/Unschedulable scan, this path plans to
+// move every replica cluster had, since cluster is no longer reachable at
+// all; applyPlans still clamps the move to cluster's MinReplicas and the
+// destination's MaxReplicas, so fewer replicas may actually move.
+//
+// The pre-deletion snapshot the hook provides is deliberately not used:
+// it holds raw target objects for whatever single resource kind the calling
+// util.FederatedInformer was built over, which cannot be mapped back to the
+// FederatedObjects that own them (no cross-cluster owner reference exists),
+// so it cannot tell us anything migratableFederatedObjects's re-list plus a
+// placement/override match doesn't already. The parameter is kept,
+// unused, solely to satisfy the hook's fixed signature.
+func (c *Controller) ClusterUnavailable(cluster *fedv1a1.FederatedCluster, _ []interface{}) {
+	fedObjects, err := c.migratableFederatedObjects()
+	if err != nil {
+		klog.Errorf("automigration: failed to list FederatedObjects while handling loss of cluster %q: %v", cluster.Name, err)
+		return
+	}
+
+	var affected []*fedv1b1.FederatedObject
+	for _, fedObject := range fedObjects {
+		if clusterOverride(fedObject, cluster.Name) != nil || clusterReference(fedObject, cluster.Name) != nil {
+			affected = append(affected, fedObject)
+		}
+	}
+	c.lastKnownState[cluster.Name] = affected
+	klog.V(2).Infof("Cluster %q became unavailable with %d opted-in FederatedObjects placed there; migrating their replicas", cluster.Name, len(affected))
+
+	remainingClusters, err := c.informer.GetReadyClusters()
+	if err != nil {
+		klog.Errorf("automigration: failed to list ready clusters while evacuating cluster %q: %v", cluster.Name, err)
+		return
+	}
+
+	for _, fedObject := range affected {
+		replicas := replicaCount(fedObject, cluster.Name)
+		if replicas <= 0 {
+			continue
+		}
+		to := leastLoadedCluster(fedObject, remainingClusters)
+		if to == "" {
+			klog.Errorf("automigration: no ready cluster available to receive %d replica(s) evacuated from %q for %s/%s", replicas, cluster.Name, fedObject.Namespace, fedObject.Name)
+			continue
+		}
+		plan := MigrationPlan{From: cluster.Name, To: to, Replicas: replicas}
+		if err := c.applyPlans(fedObject, []MigrationPlan{plan}); err != nil {
+			klog.Errorf("automigration: failed to evacuate %s/%s from cluster %q: %v", fedObject.Namespace, fedObject.Name, cluster.Name, err)
+		}
+	}
+}
+
+// LastKnownState returns the FederatedObjects ClusterUnavailable last
+// recorded as placed in clusterName before evacuating it, for callers (e.g.
+// a status endpoint or test) that need to inspect what a cluster loss
+// triggered migration for.
+func (c *Controller) LastKnownState(clusterName string) []*fedv1b1.FederatedObject {
+	return c.lastKnownState[clusterName]
+}
+
+// Run starts the periodic unschedulable-replica scan. It blocks until
+// stopChan is closed.
+func (c *Controller) Run(stopChan <-chan struct{}) {
+	wait.Until(c.schedulePendingMigrations, schedulePeriod, stopChan)
+}
+
+// migratableFederatedObjects lists every FederatedObject carrying
+// AutoMigrationAnnotation.
+func (c *Controller) migratableFederatedObjects() ([]*fedv1b1.FederatedObject, error) {
+	fedObjects, err := c.client.List()
+	if err != nil {
+		return nil, err
+	}
+	var migratable []*fedv1b1.FederatedObject
+	for _, fedObject := range fedObjects {
+		if _, enabled := fedObject.Annotations[AutoMigrationAnnotation]; enabled {
+			migratable = append(migratable, fedObject)
+		}
+	}
+	return migratable, nil
+}
+
+// schedulePendingMigrations scans every opted-in FederatedObject for
+// replicas that have been Pending and unschedulable, beyond its configured
+// keep-unschedulable count, in any of its placed clusters, plans moving the
+// excess to another of its placed clusters that isn't itself reporting
+// unschedulable replicas, and applies the resulting plans.
+func (c *Controller) schedulePendingMigrations() {
+	fedObjects, err := c.migratableFederatedObjects()
+	if err != nil {
+		klog.Errorf("automigration: failed to list FederatedObjects: %v", err)
+		return
+	}
+
+	for _, fedObject := range fedObjects {
+		c.schedulePendingMigrationsForObject(fedObject)
+	}
+}
+
+func (c *Controller) schedulePendingMigrationsForObject(fedObject *fedv1b1.FederatedObject) {
+	threshold := unschedulableThreshold(fedObject)
+	keepUnschedulable := keepUnschedulableReplicas(fedObject)
+
+	placedClusters := fedObject.Spec.Placement.Clusters
+	unschedulableByCluster := make(map[string]int64, len(placedClusters))
+	for _, cluster := range placedClusters {
+		count, err := c.unschedulableReplicas(fedObject, cluster.Name, threshold)
+		if err != nil {
+			klog.Errorf("automigration: failed to count unschedulable pods for %s/%s in cluster %q: %v", fedObject.Namespace, fedObject.Name, cluster.Name, err)
+			continue
+		}
+		if count > 0 {
+			unschedulableByCluster[cluster.Name] = count
+		}
+	}
+	if len(unschedulableByCluster) == 0 {
+		return
+	}
+
+	plans := planMigrations(unschedulableByCluster, placedClusters, keepUnschedulable)
+	if len(plans) == 0 {
+		return
+	}
+	if err := c.applyPlans(fedObject, plans); err != nil {
+		klog.Errorf("automigration: failed to apply migration plan(s) for %s/%s: %v", fedObject.Namespace, fedObject.Name, err)
+	}
+}
+
+// planMigrations pairs each cluster with excess unschedulable replicas
+// (those beyond keepUnschedulable) with a placed cluster not present in
+// unschedulableByCluster to absorb them. Constraint clamping against
+// MinReplicas/MaxReplicas happens in applyPlans, once the current override
+// state is available to compute against.
+func planMigrations(unschedulableByCluster map[string]int64, placedClusters []fedv1b1.ClusterReference, keepUnschedulable int64) []MigrationPlan {
+	var healthy []string
+	for _, cluster := range placedClusters {
+		if _, unschedulable := unschedulableByCluster[cluster.Name]; !unschedulable {
+			healthy = append(healthy, cluster.Name)
+		}
+	}
+	if len(healthy) == 0 {
+		return nil
+	}
+
+	var plans []MigrationPlan
+	next := 0
+	for clusterName, count := range unschedulableByCluster {
+		excess := count - keepUnschedulable
+		if excess <= 0 {
+			continue
+		}
+		to := healthy[next%len(healthy)]
+		next++
+		plans = append(plans, MigrationPlan{From: clusterName, To: to, Replicas: excess})
+	}
+	return plans
+}
+
+// applyPlans persists plans onto fedObject's ClusterOverride.Replicas,
+// clamping each adjustment to the destination's MaxReplicas and the
+// source's MinReplicas (per the placed ClusterReference), then updates
+// fedObject through c.client. A plan reduced to zero replicas by clamping is
+// dropped rather than applied. The whole read-modify-write cycle runs under
+// retry.RetryOnConflict: fedObject is a possibly-stale snapshot taken during
+// a scan, and another writer (a user edit, or this same controller reacting
+// to a different cluster) can update the same FederatedObject between the
+// scan and this call, so each attempt re-fetches the latest version rather
+// than blindly overwriting it.
+func (c *Controller) applyPlans(fedObject *fedv1b1.FederatedObject, plans []MigrationPlan) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		latest, err := c.client.Get(fedObject.Namespace, fedObject.Name)
+		if err != nil {
+			return err
+		}
+
+		var changed bool
+		for _, plan := range plans {
+			replicas := clampToConstraints(latest, plan)
+			if replicas <= 0 {
+				klog.V(2).Infof("automigration: dropping plan to move %d replica(s) of %s/%s from %q to %q; fully constrained by min/max replicas", plan.Replicas, fedObject.Namespace, fedObject.Name, plan.From, plan.To)
+				continue
+			}
+			klog.V(2).Infof("automigration: moving %d replica(s) of %s/%s from cluster %q to cluster %q", replicas, fedObject.Namespace, fedObject.Name, plan.From, plan.To)
+			adjustOverrideReplicas(latest, plan.From, -replicas)
+			adjustOverrideReplicas(latest, plan.To, replicas)
+			changed = true
+		}
+		if !changed {
+			return nil
+		}
+
+		_, err = c.client.Update(latest)
+		return err
+	})
+}
+
+// clampToConstraints returns how many of plan.Replicas can actually move
+// without taking plan.From below its MinReplicas or plan.To above its
+// MaxReplicas.
+func clampToConstraints(fedObject *fedv1b1.FederatedObject, plan MigrationPlan) int64 {
+	replicas := plan.Replicas
+
+	if fromRef := clusterReference(fedObject, plan.From); fromRef != nil && fromRef.MinReplicas != nil {
+		fromCount := replicaCount(fedObject, plan.From)
+		if allowed := fromCount - *fromRef.MinReplicas; allowed < replicas {
+			replicas = allowed
+		}
+	}
+	if toRef := clusterReference(fedObject, plan.To); toRef != nil && toRef.MaxReplicas != nil {
+		toCount := replicaCount(fedObject, plan.To)
+		if allowed := *toRef.MaxReplicas - toCount; allowed < replicas {
+			replicas = allowed
+		}
+	}
+	if replicas < 0 {
+		replicas = 0
+	}
+	return replicas
+}
+
+// unschedulableReplicas counts pods belonging to fedObject's template, in
+// cluster's typed Pod store, that have sat Pending and unschedulable for
+// longer than threshold.
+func (c *Controller) unschedulableReplicas(fedObject *fedv1b1.FederatedObject, cluster string, threshold time.Duration) (int64, error) {
+	podStore := c.informer.GetPodStore()
+	if podStore == nil {
+		return 0, nil
+	}
+
+	selector, err := templateSelector(fedObject)
+	if err != nil || selector == nil {
+		return 0, err
+	}
+
+	pods, err := podStore.GetPodsByPhase(cluster, apiv1.PodPending)
+	if err != nil {
+		return 0, err
+	}
+
+	var count int64
+	for _, pod := range pods {
+		if pod.Namespace != fedObject.Namespace {
+			continue
+		}
+		if !selector.Matches(labels.Set(pod.Labels)) {
+			continue
+		}
+		if isLongPendingUnschedulable(pod, threshold) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// isLongPendingUnschedulable reports whether pod is phase Pending, has a
+// PodScheduled condition of status False and reason Unschedulable, and that
+// condition transitioned more than threshold ago.
+func isLongPendingUnschedulable(pod *apiv1.Pod, threshold time.Duration) bool {
+	if pod.Status.Phase != apiv1.PodPending {
+		return false
+	}
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type != apiv1.PodScheduled {
+			continue
+		}
+		if condition.Status != apiv1.ConditionFalse || condition.Reason != "Unschedulable" {
+			return false
+		}
+		return time.Since(condition.LastTransitionTime.Time) > threshold
+	}
+	return false
+}
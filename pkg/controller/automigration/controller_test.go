@@ -0,0 +1,239 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package automigration
+
+import (
+	"testing"
+	"time"
+
+	apiv1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	fedv1b1 "sigs.k8s.io/kubefed/pkg/apis/core/v1beta1"
+)
+
+func newTestPod(phase apiv1.PodPhase, scheduledStatus apiv1.ConditionStatus, reason string, transitioned time.Time) *apiv1.Pod {
+	return &apiv1.Pod{
+		Status: apiv1.PodStatus{
+			Phase: phase,
+			Conditions: []apiv1.PodCondition{
+				{
+					Type:               apiv1.PodScheduled,
+					Status:             scheduledStatus,
+					Reason:             reason,
+					LastTransitionTime: metav1.NewTime(transitioned),
+				},
+			},
+		},
+	}
+}
+
+func TestIsLongPendingUnschedulable(t *testing.T) {
+	threshold := 60 * time.Second
+
+	tests := map[string]struct {
+		pod  *apiv1.Pod
+		want bool
+	}{
+		"long pending and unschedulable": {
+			pod:  newTestPod(apiv1.PodPending, apiv1.ConditionFalse, "Unschedulable", time.Now().Add(-2*time.Minute)),
+			want: true,
+		},
+		"recently pending and unschedulable": {
+			pod:  newTestPod(apiv1.PodPending, apiv1.ConditionFalse, "Unschedulable", time.Now()),
+			want: false,
+		},
+		"pending but scheduled": {
+			pod:  newTestPod(apiv1.PodPending, apiv1.ConditionTrue, "", time.Now().Add(-2*time.Minute)),
+			want: false,
+		},
+		"running": {
+			pod:  newTestPod(apiv1.PodRunning, apiv1.ConditionFalse, "Unschedulable", time.Now().Add(-2*time.Minute)),
+			want: false,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := isLongPendingUnschedulable(test.pod, threshold); got != test.want {
+				t.Errorf("isLongPendingUnschedulable() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestPlanMigrations(t *testing.T) {
+	placedClusters := []fedv1b1.ClusterReference{{Name: "a"}, {Name: "b"}, {Name: "c"}}
+
+	unschedulableByCluster := map[string]int64{"a": 5}
+	plans := planMigrations(unschedulableByCluster, placedClusters, 2)
+
+	if len(plans) != 1 {
+		t.Fatalf("expected exactly one plan, got %d: %+v", len(plans), plans)
+	}
+	plan := plans[0]
+	if plan.From != "a" {
+		t.Errorf("expected plan to move replicas from cluster %q, got %q", "a", plan.From)
+	}
+	if plan.To != "b" && plan.To != "c" {
+		t.Errorf("expected plan to move replicas to a healthy cluster, got %q", plan.To)
+	}
+	if plan.Replicas != 3 {
+		t.Errorf("expected plan to move 3 replicas (5 unschedulable - 2 kept), got %d", plan.Replicas)
+	}
+}
+
+func TestPlanMigrationsNoHealthyCluster(t *testing.T) {
+	placedClusters := []fedv1b1.ClusterReference{{Name: "a"}, {Name: "b"}}
+	unschedulableByCluster := map[string]int64{"a": 5, "b": 3}
+
+	if plans := planMigrations(unschedulableByCluster, placedClusters, 0); len(plans) != 0 {
+		t.Errorf("expected no plans when every placed cluster is unschedulable, got %+v", plans)
+	}
+}
+
+func TestPlanMigrationsWithinKeepThreshold(t *testing.T) {
+	placedClusters := []fedv1b1.ClusterReference{{Name: "a"}, {Name: "b"}}
+	unschedulableByCluster := map[string]int64{"a": 2}
+
+	if plans := planMigrations(unschedulableByCluster, placedClusters, 2); len(plans) != 0 {
+		t.Errorf("expected no plans when unschedulable count does not exceed keepUnschedulable, got %+v", plans)
+	}
+}
+
+func TestMigratableFederatedObjectsGating(t *testing.T) {
+	enabled := &fedv1b1.FederatedObject{
+		ObjectMeta: metav1.ObjectMeta{Name: "enabled", Namespace: "ns", Annotations: map[string]string{AutoMigrationAnnotation: ""}},
+	}
+	disabled := &fedv1b1.FederatedObject{
+		ObjectMeta: metav1.ObjectMeta{Name: "disabled", Namespace: "ns"},
+	}
+
+	c := &Controller{client: &fakeFederatedObjectClient{objects: []*fedv1b1.FederatedObject{enabled, disabled}}}
+
+	got, err := c.migratableFederatedObjects()
+	if err != nil {
+		t.Fatalf("migratableFederatedObjects() returned error: %v", err)
+	}
+	if len(got) != 1 || got[0].Name != "enabled" {
+		t.Errorf("expected only the annotated FederatedObject to be returned, got %+v", got)
+	}
+}
+
+func TestClampToConstraints(t *testing.T) {
+	minReplicas := int64(1)
+	maxReplicas := int64(4)
+	fedObject := &fedv1b1.FederatedObject{
+		Spec: fedv1b1.FederatedObjectSpec{
+			Placement: fedv1b1.PlacementSpec{
+				Clusters: []fedv1b1.ClusterReference{
+					{Name: "a", MinReplicas: &minReplicas},
+					{Name: "b", MaxReplicas: &maxReplicas},
+				},
+			},
+			Overrides: []fedv1b1.ClusterOverride{
+				{ClusterName: "a", Replicas: int64Ptr(3)},
+				{ClusterName: "b", Replicas: int64Ptr(3)},
+			},
+		},
+	}
+
+	got := clampToConstraints(fedObject, MigrationPlan{From: "a", To: "b", Replicas: 5})
+	if got != 1 {
+		t.Errorf("expected plan to be clamped to 1 replica (min(3-1 from source, 4-3 to dest)), got %d", got)
+	}
+}
+
+func TestLastKnownState(t *testing.T) {
+	fedObject := &fedv1b1.FederatedObject{ObjectMeta: metav1.ObjectMeta{Name: "evacuated", Namespace: "ns"}}
+	c := NewController(&fakeFederatedObjectClient{}, nil)
+	c.lastKnownState["cluster-a"] = []*fedv1b1.FederatedObject{fedObject}
+
+	got := c.LastKnownState("cluster-a")
+	if len(got) != 1 || got[0].Name != "evacuated" {
+		t.Errorf("expected LastKnownState to return the recorded snapshot, got %+v", got)
+	}
+	if got := c.LastKnownState("cluster-b"); got != nil {
+		t.Errorf("expected no recorded state for an unaffected cluster, got %+v", got)
+	}
+}
+
+func int64Ptr(v int64) *int64 {
+	return &v
+}
+
+func TestApplyPlansRetriesOnConflict(t *testing.T) {
+	minReplicas := int64(0)
+	fedObject := &fedv1b1.FederatedObject{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "ns"},
+		Spec: fedv1b1.FederatedObjectSpec{
+			Placement: fedv1b1.PlacementSpec{Clusters: []fedv1b1.ClusterReference{{Name: "a", MinReplicas: &minReplicas}, {Name: "b"}}},
+		},
+	}
+	client := &fakeFederatedObjectClient{objects: []*fedv1b1.FederatedObject{fedObject}, conflictsBeforeSuccess: 2}
+	c := &Controller{client: client}
+
+	err := c.applyPlans(fedObject, []MigrationPlan{{From: "a", To: "b", Replicas: 1}})
+	if err != nil {
+		t.Fatalf("applyPlans() returned error: %v", err)
+	}
+	if client.getCalls != 3 {
+		t.Errorf("expected Get to be retried until the simulated conflicts were exhausted (3 calls), got %d", client.getCalls)
+	}
+	if len(client.updated) != 1 {
+		t.Fatalf("expected exactly one successful Update, got %d", len(client.updated))
+	}
+	if got := replicaCount(client.updated[0], "b"); got != 2 {
+		t.Errorf("expected cluster b to end up with 2 replicas (1 assumed by default plus 1 moved), got %d", got)
+	}
+}
+
+type fakeFederatedObjectClient struct {
+	objects []*fedv1b1.FederatedObject
+	updated []*fedv1b1.FederatedObject
+
+	// conflictsBeforeSuccess, if non-zero, makes Update return a Conflict
+	// error that many times before succeeding, to exercise applyPlans's
+	// retry.RetryOnConflict wrapping.
+	conflictsBeforeSuccess int
+	getCalls               int
+}
+
+func (f *fakeFederatedObjectClient) List() ([]*fedv1b1.FederatedObject, error) {
+	return f.objects, nil
+}
+
+func (f *fakeFederatedObjectClient) Get(namespace, name string) (*fedv1b1.FederatedObject, error) {
+	f.getCalls++
+	for _, obj := range f.objects {
+		if obj.Namespace == namespace && obj.Name == name {
+			return obj.DeepCopyObject().(*fedv1b1.FederatedObject), nil
+		}
+	}
+	return nil, apierrors.NewNotFound(schema.GroupResource{Resource: "federatedobjects"}, name)
+}
+
+func (f *fakeFederatedObjectClient) Update(fedObject *fedv1b1.FederatedObject) (*fedv1b1.FederatedObject, error) {
+	if f.conflictsBeforeSuccess > 0 {
+		f.conflictsBeforeSuccess--
+		return nil, apierrors.NewConflict(schema.GroupResource{Resource: "federatedobjects"}, fedObject.Name, nil)
+	}
+	f.updated = append(f.updated, fedObject)
+	return fedObject, nil
+}
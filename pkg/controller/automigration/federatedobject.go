@@ -0,0 +1,176 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package automigration
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+
+	fedv1a1 "github.com/kubernetes-sigs/federation-v2/pkg/apis/core/v1alpha1"
+	fedv1b1 "sigs.k8s.io/kubefed/pkg/apis/core/v1beta1"
+)
+
+// defaultTemplateReplicas is the replica count assumed for a cluster that
+// has no ClusterOverride.Replicas yet and whose template does not declare
+// spec.replicas (e.g. it is not a Deployment/ReplicaSet/StatefulSet-shaped
+// kind), matching how such a template is propagated unmodified today.
+const defaultTemplateReplicas = int64(1)
+
+// unschedulableThreshold returns fedObject's configured
+// UnschedulableThresholdAnnotation, or defaultUnschedulableThreshold if it
+// is absent or unparseable.
+func unschedulableThreshold(fedObject *fedv1b1.FederatedObject) time.Duration {
+	value, ok := fedObject.Annotations[UnschedulableThresholdAnnotation]
+	if !ok {
+		return defaultUnschedulableThreshold
+	}
+	threshold, err := time.ParseDuration(value)
+	if err != nil {
+		return defaultUnschedulableThreshold
+	}
+	return threshold
+}
+
+// keepUnschedulableReplicas returns fedObject's configured
+// KeepUnschedulableAnnotation, or defaultKeepUnschedulable if it is absent
+// or unparseable.
+func keepUnschedulableReplicas(fedObject *fedv1b1.FederatedObject) int64 {
+	value, ok := fedObject.Annotations[KeepUnschedulableAnnotation]
+	if !ok {
+		return defaultKeepUnschedulable
+	}
+	keep, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return defaultKeepUnschedulable
+	}
+	return keep
+}
+
+// templateSelector returns the pod label selector declared by fedObject's
+// template (spec.selector.matchLabels, as used by Deployment, ReplicaSet,
+// StatefulSet and Job), or nil if the template has none. There is no
+// cross-cluster owner reference linking a member cluster's pods back to the
+// FederatedObject that placed them, so matching by the template's own
+// selector is how automigration finds the pods it is allowed to migrate.
+func templateSelector(fedObject *fedv1b1.FederatedObject) (labels.Selector, error) {
+	template := &unstructured.Unstructured{}
+	if err := json.Unmarshal(fedObject.Spec.Template.Raw, &template.Object); err != nil {
+		return nil, err
+	}
+
+	matchLabels, found, err := unstructured.NestedStringMap(template.Object, "spec", "selector", "matchLabels")
+	if err != nil || !found || len(matchLabels) == 0 {
+		return nil, err
+	}
+	return labels.SelectorFromSet(matchLabels), nil
+}
+
+// templateReplicas returns the replica count fedObject's template declares
+// via spec.replicas, or defaultTemplateReplicas if the template has none.
+func templateReplicas(fedObject *fedv1b1.FederatedObject) int64 {
+	template := &unstructured.Unstructured{}
+	if err := json.Unmarshal(fedObject.Spec.Template.Raw, &template.Object); err != nil {
+		return defaultTemplateReplicas
+	}
+	replicas, found, err := unstructured.NestedInt64(template.Object, "spec", "replicas")
+	if err != nil || !found {
+		return defaultTemplateReplicas
+	}
+	return replicas
+}
+
+// clusterReference returns the ClusterReference for clusterName in
+// fedObject's placement, or nil if it is not placed there.
+func clusterReference(fedObject *fedv1b1.FederatedObject, clusterName string) *fedv1b1.ClusterReference {
+	for i := range fedObject.Spec.Placement.Clusters {
+		if fedObject.Spec.Placement.Clusters[i].Name == clusterName {
+			return &fedObject.Spec.Placement.Clusters[i]
+		}
+	}
+	return nil
+}
+
+// clusterOverride returns the ClusterOverride for clusterName in fedObject,
+// or nil if there isn't one yet.
+func clusterOverride(fedObject *fedv1b1.FederatedObject, clusterName string) *fedv1b1.ClusterOverride {
+	for i := range fedObject.Spec.Overrides {
+		if fedObject.Spec.Overrides[i].ClusterName == clusterName {
+			return &fedObject.Spec.Overrides[i]
+		}
+	}
+	return nil
+}
+
+// replicaCount returns the replica count fedObject currently carries for
+// clusterName: its ClusterOverride.Replicas if one is set, otherwise the
+// template's own declared replica count.
+func replicaCount(fedObject *fedv1b1.FederatedObject, clusterName string) int64 {
+	if override := clusterOverride(fedObject, clusterName); override != nil && override.Replicas != nil {
+		return *override.Replicas
+	}
+	return templateReplicas(fedObject)
+}
+
+// adjustOverrideReplicas adds delta to clusterName's current replicaCount in
+// fedObject, creating a ClusterOverride for it if one does not already
+// exist, and clamps the result to zero.
+func adjustOverrideReplicas(fedObject *fedv1b1.FederatedObject, clusterName string, delta int64) {
+	replicas := replicaCount(fedObject, clusterName) + delta
+	if replicas < 0 {
+		replicas = 0
+	}
+
+	if override := clusterOverride(fedObject, clusterName); override != nil {
+		override.Replicas = &replicas
+		return
+	}
+	fedObject.Spec.Overrides = append(fedObject.Spec.Overrides, fedv1b1.ClusterOverride{
+		ClusterName: clusterName,
+		Replicas:    &replicas,
+	})
+}
+
+// leastLoadedCluster returns the name of the placed, ready cluster with the
+// fewest current replicas of fedObject that has not already reached its
+// MaxReplicas, or "" if none qualify.
+func leastLoadedCluster(fedObject *fedv1b1.FederatedObject, readyClusters []*fedv1a1.FederatedCluster) string {
+	ready := make(map[string]bool, len(readyClusters))
+	for _, cluster := range readyClusters {
+		ready[cluster.Name] = true
+	}
+
+	best := ""
+	var bestCount int64
+	for _, ref := range fedObject.Spec.Placement.Clusters {
+		if !ready[ref.Name] {
+			continue
+		}
+		count := replicaCount(fedObject, ref.Name)
+		if ref.MaxReplicas != nil && count >= *ref.MaxReplicas {
+			continue
+		}
+		if best == "" || count < bestCount {
+			best = ref.Name
+			bestCount = count
+		}
+	}
+	return best
+}
@@ -0,0 +1,106 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dispatch
+
+import (
+	"encoding/json"
+
+	"github.com/pkg/errors"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	fedv1b1 "sigs.k8s.io/kubefed/pkg/apis/core/v1beta1"
+	"sigs.k8s.io/kubefed/pkg/controller/util"
+)
+
+// InformerForGVK looks up the util.FederatedInformer that was configured
+// for the given target apiVersion/kind, so a single dispatch entry point
+// can serve every FederatedObject/ClusterFederatedObject regardless of what
+// kind its template holds, rather than requiring one controller wired per
+// targetKind.
+type InformerForGVK func(apiVersion, kind string) (util.FederatedInformer, bool)
+
+// NewUnifiedDispatcher builds an UnmanagedDispatcher for the namespaced
+// fedObject by resolving its embedded template's GroupVersionKind through
+// restMapper and acquiring the matching FederatedInformer from
+// informerForGVK. It replaces the old pattern of wiring one
+// UnmanagedDispatcher per targetKind/targetName pair through a type-specific
+// controller: callers now construct a dispatcher per FederatedObject as it
+// is reconciled, and the same dispatch.UnmanagedDispatcher.Delete /
+// RemoveManagedLabel operations apply unchanged regardless of kind.
+func NewUnifiedDispatcher(restMapper meta.RESTMapper, informerForGVK InformerForGVK, fedObject *fedv1b1.FederatedObject) (UnmanagedDispatcher, error) {
+	return newUnifiedDispatcher(restMapper, informerForGVK, fedObject.Namespace, fedObject.Name, &fedObject.Spec)
+}
+
+// NewUnifiedDispatcherForClusterObject is the NewUnifiedDispatcher
+// counterpart for the cluster-scoped ClusterFederatedObject, whose target
+// resource therefore has no federation namespace to fall back to when the
+// template itself does not declare one.
+func NewUnifiedDispatcherForClusterObject(restMapper meta.RESTMapper, informerForGVK InformerForGVK, fedObject *fedv1b1.ClusterFederatedObject) (UnmanagedDispatcher, error) {
+	return newUnifiedDispatcher(restMapper, informerForGVK, "", fedObject.Name, &fedObject.Spec)
+}
+
+func newUnifiedDispatcher(restMapper meta.RESTMapper, informerForGVK InformerForGVK, namespace, name string, spec *fedv1b1.FederatedObjectSpec) (UnmanagedDispatcher, error) {
+	informer, targetName, gvk, err := ResolveTemplateTarget(restMapper, informerForGVK, namespace, name, spec)
+	if err != nil {
+		return nil, err
+	}
+
+	clientAccessor := func(clusterName string) (util.ResourceClient, error) {
+		return informer.GetClientForCluster(clusterName)
+	}
+
+	dispatcher := newOperationDispatcher(clientAccessor, nil)
+	return newUnmanagedDispatcher(dispatcher, nil, gvk.Kind, targetName), nil
+}
+
+// ResolveTemplateTarget parses spec.Template, maps its GroupVersionKind
+// through restMapper and looks up the matching FederatedInformer through
+// informerForGVK, returning the information a caller needs to read or
+// dispatch to the target's current member cluster copies. namespace is the
+// owning FederatedObject's namespace, used only as a fallback when the
+// template itself declares none; pass "" when there is no namespace to fall
+// back to, as for a ClusterFederatedObject. It is exported so callers that need the informer and
+// target name directly - such as a sync reconciler collecting current
+// member cluster copies before cascading deletion - can reuse the same
+// resolution NewUnifiedDispatcher performs instead of duplicating it.
+func ResolveTemplateTarget(restMapper meta.RESTMapper, informerForGVK InformerForGVK, namespace, name string, spec *fedv1b1.FederatedObjectSpec) (util.FederatedInformer, util.QualifiedName, schema.GroupVersionKind, error) {
+	template := &unstructured.Unstructured{}
+	if err := json.Unmarshal(spec.Template.Raw, &template.Object); err != nil {
+		return nil, util.QualifiedName{}, schema.GroupVersionKind{}, errors.Wrapf(err, "failed to parse template of %q", name)
+	}
+
+	gvk := template.GroupVersionKind()
+	if _, err := restMapper.RESTMapping(gvk.GroupKind(), gvk.Version); err != nil {
+		return nil, util.QualifiedName{}, schema.GroupVersionKind{}, errors.Wrapf(err, "failed to map %s for %q", gvk, name)
+	}
+
+	informer, ok := informerForGVK(gvk.GroupVersion().String(), gvk.Kind)
+	if !ok {
+		return nil, util.QualifiedName{}, schema.GroupVersionKind{}, errors.Errorf("no federated informer registered for %s, needed by %q", gvk, name)
+	}
+
+	targetNamespace := template.GetNamespace()
+	if targetNamespace == "" {
+		targetNamespace = namespace
+	}
+	targetName := util.QualifiedName{Namespace: targetNamespace, Name: template.GetName()}
+
+	return informer, targetName, gvk, nil
+}
@@ -0,0 +1,122 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dispatch
+
+import (
+	"encoding/json"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	fedv1b1 "sigs.k8s.io/kubefed/pkg/apis/core/v1beta1"
+	"sigs.k8s.io/kubefed/pkg/controller/util"
+)
+
+var testDeploymentGVK = schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}
+
+func testRESTMapper() meta.RESTMapper {
+	mapper := meta.NewDefaultRESTMapper([]schema.GroupVersion{testDeploymentGVK.GroupVersion()})
+	mapper.Add(testDeploymentGVK, meta.RESTScopeNamespace)
+	return mapper
+}
+
+func testTemplateSpec(t *testing.T, namespace, name string) *fedv1b1.FederatedObjectSpec {
+	t.Helper()
+	template := map[string]interface{}{
+		"apiVersion": testDeploymentGVK.GroupVersion().String(),
+		"kind":       testDeploymentGVK.Kind,
+		"metadata": map[string]interface{}{
+			"name": name,
+		},
+	}
+	if namespace != "" {
+		template["metadata"].(map[string]interface{})["namespace"] = namespace
+	}
+	raw, err := json.Marshal(template)
+	if err != nil {
+		t.Fatalf("failed to marshal test template: %v", err)
+	}
+	return &fedv1b1.FederatedObjectSpec{Template: runtime.RawExtension{Raw: raw}}
+}
+
+func TestResolveTemplateTargetResolvesInformer(t *testing.T) {
+	var gotAPIVersion, gotKind string
+	informerForGVK := func(apiVersion, kind string) (util.FederatedInformer, bool) {
+		gotAPIVersion, gotKind = apiVersion, kind
+		return nil, true
+	}
+
+	_, targetName, gvk, err := ResolveTemplateTarget(testRESTMapper(), informerForGVK, "fallback-ns", "test", testTemplateSpec(t, "template-ns", "my-deployment"))
+	if err != nil {
+		t.Fatalf("ResolveTemplateTarget() returned error: %v", err)
+	}
+	if gvk != testDeploymentGVK {
+		t.Errorf("expected gvk %v, got %v", testDeploymentGVK, gvk)
+	}
+	if gotAPIVersion != testDeploymentGVK.GroupVersion().String() || gotKind != testDeploymentGVK.Kind {
+		t.Errorf("expected informerForGVK to be called with %s/%s, got %s/%s", testDeploymentGVK.GroupVersion(), testDeploymentGVK.Kind, gotAPIVersion, gotKind)
+	}
+	if targetName.Namespace != "template-ns" || targetName.Name != "my-deployment" {
+		t.Errorf("expected target name template-ns/my-deployment, got %s/%s", targetName.Namespace, targetName.Name)
+	}
+}
+
+func TestResolveTemplateTargetFallsBackToNamespace(t *testing.T) {
+	informerForGVK := func(apiVersion, kind string) (util.FederatedInformer, bool) { return nil, true }
+
+	_, targetName, _, err := ResolveTemplateTarget(testRESTMapper(), informerForGVK, "fallback-ns", "test", testTemplateSpec(t, "", "my-deployment"))
+	if err != nil {
+		t.Fatalf("ResolveTemplateTarget() returned error: %v", err)
+	}
+	if targetName.Namespace != "fallback-ns" {
+		t.Errorf("expected target namespace to fall back to the owning object's namespace, got %q", targetName.Namespace)
+	}
+}
+
+func TestResolveTemplateTargetUnmappedGVK(t *testing.T) {
+	emptyMapper := meta.NewDefaultRESTMapper(nil)
+	informerForGVK := func(apiVersion, kind string) (util.FederatedInformer, bool) {
+		t.Fatalf("informerForGVK should not be called when restMapper cannot map the GVK")
+		return nil, false
+	}
+
+	_, _, _, err := ResolveTemplateTarget(emptyMapper, informerForGVK, "ns", "test", testTemplateSpec(t, "ns", "my-deployment"))
+	if err == nil {
+		t.Fatalf("expected an error when restMapper has no mapping for the template's GVK")
+	}
+}
+
+func TestResolveTemplateTargetNoInformerRegistered(t *testing.T) {
+	informerForGVK := func(apiVersion, kind string) (util.FederatedInformer, bool) { return nil, false }
+
+	_, _, _, err := ResolveTemplateTarget(testRESTMapper(), informerForGVK, "ns", "test", testTemplateSpec(t, "ns", "my-deployment"))
+	if err == nil {
+		t.Fatalf("expected an error when informerForGVK reports no informer for the resolved GVK")
+	}
+}
+
+func TestResolveTemplateTargetInvalidTemplate(t *testing.T) {
+	informerForGVK := func(apiVersion, kind string) (util.FederatedInformer, bool) { return nil, true }
+	spec := &fedv1b1.FederatedObjectSpec{Template: runtime.RawExtension{Raw: []byte("not json")}}
+
+	_, _, _, err := ResolveTemplateTarget(testRESTMapper(), informerForGVK, "ns", "test", spec)
+	if err == nil {
+		t.Fatalf("expected an error when the template cannot be parsed as JSON")
+	}
+}
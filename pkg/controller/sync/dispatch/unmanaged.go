@@ -17,6 +17,8 @@ limitations under the License.
 package dispatch
 
 import (
+	"time"
+
 	"github.com/pkg/errors"
 
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
@@ -29,14 +31,56 @@ import (
 	"sigs.k8s.io/kubefed/pkg/controller/util"
 )
 
+const (
+	// foregroundDeletionMaxAttempts bounds how long DeleteWithPolicy polls a member
+	// cluster waiting for the DeleteForeground policy's dependents to be
+	// garbage collected before giving up and reporting StatusError, which
+	// allows the calling reconciler to retry on its next sync.
+	foregroundDeletionMaxAttempts  = 30
+	foregroundDeletionPollInterval = 2 * time.Second
+)
+
 const eventTemplate = "%s %s %q in cluster %q"
 
+// CascadingDeletionPolicy determines how the member cluster's garbage
+// collector should treat the dependents (e.g. a Deployment's ReplicaSets
+// and Pods) of a deleted object. It does not cover orphaning a federated
+// resource from federation management, which is handled by
+// deletionhelper.DeletionHelper via RemoveManagedLabel instead of Delete.
+type CascadingDeletionPolicy string
+
+const (
+	// DeleteBackground deletes the object immediately and lets the member
+	// cluster's garbage collector remove dependents in the background.
+	DeleteBackground CascadingDeletionPolicy = "Background"
+	// DeleteForeground blocks removal of the object until its dependents
+	// have been deleted by the member cluster's garbage collector.
+	DeleteForeground CascadingDeletionPolicy = "Foreground"
+)
+
+func deletionPropagationPolicy(policy CascadingDeletionPolicy) *metav1.DeletionPropagation {
+	if policy == DeleteForeground {
+		foreground := metav1.DeletePropagationForeground
+		return &foreground
+	}
+	background := metav1.DeletePropagationBackground
+	return &background
+}
+
 // UnmanagedDispatcher dispatches operations to member clusters for
 // resources that are no longer managed by a federated resource.
 type UnmanagedDispatcher interface {
 	OperationDispatcher
 
+	// Delete issues a delete with the DeleteBackground cascading policy,
+	// preserving the behavior existing callers rely on.
 	Delete(clusterName string)
+
+	// DeleteWithPolicy behaves like Delete but lets the caller select the
+	// cascading deletion policy (e.g. deletionhelper.DeletionHelper
+	// selecting DeleteForeground/DeleteBackground per PropagationPolicy).
+	DeleteWithPolicy(clusterName string, policy CascadingDeletionPolicy)
+
 	RemoveManagedLabel(clusterName string, clusterObj *unstructured.Unstructured)
 }
 
@@ -68,6 +112,10 @@ func (d *unmanagedDispatcherImpl) Wait() (bool, error) {
 }
 
 func (d *unmanagedDispatcherImpl) Delete(clusterName string) {
+	d.DeleteWithPolicy(clusterName, DeleteBackground)
+}
+
+func (d *unmanagedDispatcherImpl) DeleteWithPolicy(clusterName string, policy CascadingDeletionPolicy) {
 	d.dispatcher.incrementOperationsInitiated()
 	const op = "delete"
 	const opContinuous = "Deleting"
@@ -78,7 +126,8 @@ func (d *unmanagedDispatcherImpl) Delete(clusterName string) {
 			d.recorder.recordEvent(clusterName, op, opContinuous)
 		}
 
-		err := client.Resources(d.targetName.Namespace).Delete(d.targetName.Name, &metav1.DeleteOptions{})
+		deleteOptions := &metav1.DeleteOptions{PropagationPolicy: deletionPropagationPolicy(policy)}
+		err := client.Resources(d.targetName.Namespace).Delete(d.targetName.Name, deleteOptions)
 		if apierrors.IsNotFound(err) {
 			err = nil
 		}
@@ -91,10 +140,38 @@ func (d *unmanagedDispatcherImpl) Delete(clusterName string) {
 			}
 			return util.StatusError
 		}
+		if policy == DeleteForeground {
+			return d.waitForForegroundDeletion(client, clusterName, op)
+		}
 		return util.StatusAllOK
 	})
 }
 
+// waitForForegroundDeletion polls the member cluster for the object's
+// disappearance so that, when DeleteForeground is in effect, the caller
+// does not consider the delete complete until the apiserver's garbage
+// collector has also removed the object's dependents.
+func (d *unmanagedDispatcherImpl) waitForForegroundDeletion(client util.ResourceClient, clusterName, op string) util.ReconciliationStatus {
+	for attempt := 0; attempt < foregroundDeletionMaxAttempts; attempt++ {
+		_, err := client.Resources(d.targetName.Namespace).Get(d.targetName.Name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			return util.StatusAllOK
+		}
+		if err != nil {
+			if d.recorder == nil {
+				wrappedErr := d.wrapOperationError(err, clusterName, op)
+				runtime.HandleError(wrappedErr)
+			} else {
+				d.recorder.recordOperationError(status.DeletionFailed, clusterName, op, err)
+			}
+			return util.StatusError
+		}
+		time.Sleep(foregroundDeletionPollInterval)
+	}
+	klog.V(2).Infof("Dependents of %s %q were not removed from cluster %q within the foreground deletion window; will retry", d.targetKind, d.targetName, clusterName)
+	return util.StatusError
+}
+
 func (d *unmanagedDispatcherImpl) RemoveManagedLabel(clusterName string, clusterObj *unstructured.Unstructured) {
 	d.dispatcher.incrementOperationsInitiated()
 	const op = "remove managed label from"
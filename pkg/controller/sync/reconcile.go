@@ -0,0 +1,156 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package sync reconciles a FederatedObject against its member cluster
+// copies: it keeps FinalizerCascadingDelete present while the object is
+// live, and once the object is marked for deletion, drives cascading
+// deletion of its copies through deletionhelper before letting the
+// finalizer go so the apiserver can garbage collect it.
+package sync
+
+import (
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	fedv1b1 "sigs.k8s.io/kubefed/pkg/apis/core/v1beta1"
+	"sigs.k8s.io/kubefed/pkg/controller/sync/dispatch"
+	"sigs.k8s.io/kubefed/pkg/controller/util/deletionhelper"
+)
+
+// FederatedObjectClient is the subset of a generated FederatedObject client
+// the reconciler needs to persist finalizer changes. It is satisfied by the
+// generated clientset's FederatedObjects(namespace) once one exists in this
+// tree; tests satisfy it with a fake.
+type FederatedObjectClient interface {
+	Update(fedObject *fedv1b1.FederatedObject) (*fedv1b1.FederatedObject, error)
+}
+
+// Reconciler drives a single FederatedObject through its finalizer
+// lifecycle: EnsureFinalizer before it is considered propagated, then
+// DeletionHelper.Delete and RemoveFinalizer once it is marked for deletion.
+// This is the call site deletionhelper.EnsureFinalizer, DeletionHelper.Delete
+// and RemoveFinalizer were written for; without it the finalizer is only
+// ever added or removed by package-internal tests, and a deleted
+// FederatedObject's member cluster copies are never actually cleaned up.
+type Reconciler struct {
+	client         FederatedObjectClient
+	restMapper     meta.RESTMapper
+	informerForGVK dispatch.InformerForGVK
+}
+
+// NewReconciler returns a Reconciler that persists finalizer changes through
+// client and resolves each FederatedObject's target kind through restMapper
+// and informerForGVK, exactly as dispatch.NewUnifiedDispatcher does.
+func NewReconciler(client FederatedObjectClient, restMapper meta.RESTMapper, informerForGVK dispatch.InformerForGVK) *Reconciler {
+	return &Reconciler{
+		client:         client,
+		restMapper:     restMapper,
+		informerForGVK: informerForGVK,
+	}
+}
+
+// Reconcile ensures fedObject carries deletionhelper.FinalizerCascadingDelete
+// while it is live, or drives its removal once fedObject.DeletionTimestamp is
+// set. A nil error is returned both when fedObject is in the right state and
+// when DeletionHelper.Delete reports it is not yet finished (see
+// reconcileDeletion); the two cases are not distinguished by the return
+// value, and cascading deletion of a resource's member cluster copies can
+// span several reconciles.
+func (r *Reconciler) Reconcile(fedObject *fedv1b1.FederatedObject) error {
+	if fedObject.DeletionTimestamp != nil {
+		return r.reconcileDeletion(fedObject)
+	}
+	return r.ensureFinalizer(fedObject)
+}
+
+func (r *Reconciler) ensureFinalizer(fedObject *fedv1b1.FederatedObject) error {
+	updated := fedObject.DeepCopyObject().(*fedv1b1.FederatedObject)
+	if !deletionhelper.EnsureFinalizer(updated) {
+		return nil
+	}
+	_, err := r.client.Update(updated)
+	return err
+}
+
+// reconcileDeletion drives fedObject's member cluster copies towards
+// deletion (or, under the Orphan policy, towards having their managed label
+// stripped) and removes FinalizerCascadingDelete once DeletionHelper.Delete
+// reports every cluster has been accounted for. If Delete reports it is not
+// yet finished, fedObject is left untouched so the next reconcile retries
+// with the clusters that are still outstanding.
+func (r *Reconciler) reconcileDeletion(fedObject *fedv1b1.FederatedObject) error {
+	if !deletionhelper.HasFinalizer(fedObject) {
+		return nil
+	}
+
+	helper, err := deletionhelper.NewDeletionHelperForFederatedObject(r.restMapper, r.informerForGVK, fedObject)
+	if err != nil {
+		return err
+	}
+
+	clusterObjs, err := r.clusterObjects(fedObject)
+	if err != nil {
+		return err
+	}
+
+	finished, err := helper.Delete(clusterObjs)
+	if err != nil || !finished {
+		return err
+	}
+
+	updated := fedObject.DeepCopyObject().(*fedv1b1.FederatedObject)
+	deletionhelper.RemoveFinalizer(updated)
+	_, err = r.client.Update(updated)
+	return err
+}
+
+// clusterObjects collects fedObject's current member cluster copy, keyed by
+// cluster name, from every cluster its target informer reports ready. A
+// cluster with no copy present (e.g. it was already deleted on a prior
+// reconcile) is simply omitted. The Reconciler keeps no record of the
+// clusters observed on earlier reconciles, so Delete is only given the
+// clusters whose copy is present now.
+func (r *Reconciler) clusterObjects(fedObject *fedv1b1.FederatedObject) (map[string]*unstructured.Unstructured, error) {
+	informer, targetName, _, err := dispatch.ResolveTemplateTarget(r.restMapper, r.informerForGVK, fedObject.Namespace, fedObject.Name, &fedObject.Spec)
+	if err != nil {
+		return nil, err
+	}
+
+	clusters, err := informer.GetReadyClusters()
+	if err != nil {
+		return nil, err
+	}
+
+	key := targetName.Name
+	if targetName.Namespace != "" {
+		key = targetName.Namespace + "/" + targetName.Name
+	}
+
+	clusterObjs := make(map[string]*unstructured.Unstructured, len(clusters))
+	for _, cluster := range clusters {
+		obj, exists, err := informer.GetTargetStore().GetByKey(cluster.Name, key)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			continue
+		}
+		if clusterObj, ok := obj.(*unstructured.Unstructured); ok {
+			clusterObjs[cluster.Name] = clusterObj
+		}
+	}
+	return clusterObjs, nil
+}
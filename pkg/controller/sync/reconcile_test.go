@@ -0,0 +1,82 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sync
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	fedv1b1 "sigs.k8s.io/kubefed/pkg/apis/core/v1beta1"
+	"sigs.k8s.io/kubefed/pkg/controller/util/deletionhelper"
+)
+
+type fakeFederatedObjectClient struct {
+	updates []*fedv1b1.FederatedObject
+}
+
+func (f *fakeFederatedObjectClient) Update(fedObject *fedv1b1.FederatedObject) (*fedv1b1.FederatedObject, error) {
+	f.updates = append(f.updates, fedObject)
+	return fedObject, nil
+}
+
+func TestReconcileEnsuresFinalizer(t *testing.T) {
+	fedObject := &fedv1b1.FederatedObject{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "ns"}}
+	client := &fakeFederatedObjectClient{}
+	r := NewReconciler(client, nil, nil)
+
+	if err := r.Reconcile(fedObject); err != nil {
+		t.Fatalf("Reconcile() returned error: %v", err)
+	}
+	if len(client.updates) != 1 {
+		t.Fatalf("expected one Update call to add the finalizer, got %d", len(client.updates))
+	}
+	if !deletionhelper.HasFinalizer(client.updates[0]) {
+		t.Errorf("expected the updated FederatedObject to carry FinalizerCascadingDelete")
+	}
+}
+
+func TestReconcileSkipsFinalizerAlreadyPresent(t *testing.T) {
+	fedObject := &fedv1b1.FederatedObject{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "ns", Finalizers: []string{deletionhelper.FinalizerCascadingDelete}},
+	}
+	client := &fakeFederatedObjectClient{}
+	r := NewReconciler(client, nil, nil)
+
+	if err := r.Reconcile(fedObject); err != nil {
+		t.Fatalf("Reconcile() returned error: %v", err)
+	}
+	if len(client.updates) != 0 {
+		t.Errorf("expected no Update call when the finalizer is already present, got %d", len(client.updates))
+	}
+}
+
+func TestReconcileDeletionSkipsWithoutFinalizer(t *testing.T) {
+	now := metav1.Now()
+	fedObject := &fedv1b1.FederatedObject{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "ns", DeletionTimestamp: &now},
+	}
+	client := &fakeFederatedObjectClient{}
+	r := NewReconciler(client, nil, nil)
+
+	if err := r.Reconcile(fedObject); err != nil {
+		t.Fatalf("Reconcile() returned error: %v", err)
+	}
+	if len(client.updates) != 0 {
+		t.Errorf("expected no Update call for a deleted object that never carried the finalizer, got %d", len(client.updates))
+	}
+}
@@ -0,0 +1,114 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package deletionhelper holds a federated resource alive, via a
+// finalizer, until its propagated copies have actually been removed from
+// every member cluster it was sent to. It mirrors the cascading-delete
+// finalizer pattern the core namespace controller uses for cluster-scoped
+// deletion (kubernetes/kubernetes#34648), adapted to federation's
+// per-cluster dispatch.
+package deletionhelper
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"sigs.k8s.io/kubefed/pkg/controller/sync/dispatch"
+)
+
+// FinalizerCascadingDelete is added to a federated resource before its
+// template is first propagated to member clusters, and is removed only
+// once every propagated copy has been confirmed deleted (or, under the
+// Orphan policy, stripped of its managed label). Its presence blocks the
+// Kubernetes API server from garbage collecting the federated resource.
+const FinalizerCascadingDelete = "kubefed.io/federation-deletion"
+
+// HasFinalizer returns whether obj already carries FinalizerCascadingDelete.
+// obj may be an *unstructured.Unstructured member cluster copy or a typed
+// federated resource such as *fedv1b1.FederatedObject; both satisfy
+// metav1.Object.
+func HasFinalizer(obj metav1.Object) bool {
+	return sets.NewString(obj.GetFinalizers()...).Has(FinalizerCascadingDelete)
+}
+
+// EnsureFinalizer adds FinalizerCascadingDelete to obj if not already
+// present, reporting whether obj was modified and therefore needs to be
+// persisted by the caller.
+func EnsureFinalizer(obj metav1.Object) bool {
+	finalizers := sets.NewString(obj.GetFinalizers()...)
+	if finalizers.Has(FinalizerCascadingDelete) {
+		return false
+	}
+	finalizers.Insert(FinalizerCascadingDelete)
+	obj.SetFinalizers(finalizers.List())
+	return true
+}
+
+// RemoveFinalizer removes FinalizerCascadingDelete from obj, reporting
+// whether obj was modified and therefore needs to be persisted by the
+// caller. Callers must only do so once DeletionHelper.Delete reports that
+// every member cluster copy has been accounted for.
+func RemoveFinalizer(obj metav1.Object) bool {
+	finalizers := sets.NewString(obj.GetFinalizers()...)
+	if !finalizers.Has(FinalizerCascadingDelete) {
+		return false
+	}
+	finalizers.Delete(FinalizerCascadingDelete)
+	obj.SetFinalizers(finalizers.List())
+	return true
+}
+
+// DeletionHelper drives cascading deletion of a federated resource's
+// member cluster copies, using dispatcher to either delete each copy or,
+// under the Orphan policy, strip its managed label so it is left behind
+// under the member cluster's own management.
+type DeletionHelper struct {
+	dispatcher dispatch.UnmanagedDispatcher
+	orphan     bool
+	policy     dispatch.CascadingDeletionPolicy
+}
+
+// NewDeletionHelper returns a DeletionHelper that dispatches through
+// dispatcher. orphan should be set from the federated resource's
+// Spec.PropagationPolicy.OrphanDependents; policy governs how dependents of
+// a non-orphaned deletion are garbage collected within each member cluster.
+func NewDeletionHelper(dispatcher dispatch.UnmanagedDispatcher, orphan bool, policy dispatch.CascadingDeletionPolicy) *DeletionHelper {
+	return &DeletionHelper{
+		dispatcher: dispatcher,
+		orphan:     orphan,
+		policy:     policy,
+	}
+}
+
+// Delete dispatches one operation per entry in clusterObjs - a delete for
+// the configured CascadingDeletionPolicy, or a managed-label removal if the
+// helper was constructed with orphan set - and waits for all of them to
+// complete. It returns (true, nil) once every cluster has been accounted
+// for, at which point the caller may remove FinalizerCascadingDelete.
+// A (false, nil) result, or clusterObjs containing fewer clusters than the
+// federated resource was last observed in, means the caller should requeue
+// and call Delete again with the outstanding clusters on its next sync.
+func (h *DeletionHelper) Delete(clusterObjs map[string]*unstructured.Unstructured) (bool, error) {
+	for clusterName, clusterObj := range clusterObjs {
+		if h.orphan {
+			h.dispatcher.RemoveManagedLabel(clusterName, clusterObj)
+		} else {
+			h.dispatcher.DeleteWithPolicy(clusterName, h.policy)
+		}
+	}
+	return h.dispatcher.Wait()
+}
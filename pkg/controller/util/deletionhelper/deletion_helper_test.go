@@ -0,0 +1,92 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deletionhelper
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	fedv1b1 "sigs.k8s.io/kubefed/pkg/apis/core/v1beta1"
+	"sigs.k8s.io/kubefed/pkg/controller/sync/dispatch"
+)
+
+func TestEnsureFinalizerAddsOnce(t *testing.T) {
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{}}
+
+	if !EnsureFinalizer(obj) {
+		t.Fatalf("expected EnsureFinalizer to report a change when the finalizer was absent")
+	}
+	if !HasFinalizer(obj) {
+		t.Errorf("expected obj to carry FinalizerCascadingDelete after EnsureFinalizer")
+	}
+	if EnsureFinalizer(obj) {
+		t.Errorf("expected EnsureFinalizer to report no change when the finalizer was already present")
+	}
+}
+
+func TestEnsureFinalizerOnTypedObject(t *testing.T) {
+	fedObject := &fedv1b1.FederatedObject{}
+
+	if !EnsureFinalizer(fedObject) {
+		t.Fatalf("expected EnsureFinalizer to report a change for a typed federated resource")
+	}
+	if !HasFinalizer(fedObject) {
+		t.Errorf("expected the typed federated resource to carry FinalizerCascadingDelete")
+	}
+}
+
+func TestRemoveFinalizer(t *testing.T) {
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{}}
+	obj.SetFinalizers([]string{FinalizerCascadingDelete, "other.example.com/finalizer"})
+
+	if !RemoveFinalizer(obj) {
+		t.Fatalf("expected RemoveFinalizer to report a change when the finalizer was present")
+	}
+	if HasFinalizer(obj) {
+		t.Errorf("expected FinalizerCascadingDelete to be gone")
+	}
+	if got := obj.GetFinalizers(); len(got) != 1 || got[0] != "other.example.com/finalizer" {
+		t.Errorf("expected unrelated finalizers to be preserved, got %v", got)
+	}
+	if RemoveFinalizer(obj) {
+		t.Errorf("expected RemoveFinalizer to report no change once the finalizer is already gone")
+	}
+}
+
+func TestCascadingDeletionPolicy(t *testing.T) {
+	if got := cascadingDeletionPolicy(fedv1b1.DeletionPolicyForeground); got != dispatch.DeleteForeground {
+		t.Errorf("expected DeletionPolicyForeground to map to dispatch.DeleteForeground, got %v", got)
+	}
+	if got := cascadingDeletionPolicy(fedv1b1.DeletionPolicyBackground); got != dispatch.DeleteBackground {
+		t.Errorf("expected DeletionPolicyBackground to map to dispatch.DeleteBackground, got %v", got)
+	}
+	if got := cascadingDeletionPolicy(""); got != dispatch.DeleteBackground {
+		t.Errorf("expected an unset DeletionPolicy to default to dispatch.DeleteBackground, got %v", got)
+	}
+}
+
+func TestNewDeletionHelper(t *testing.T) {
+	h := NewDeletionHelper(nil, true, dispatch.DeleteForeground)
+	if !h.orphan {
+		t.Errorf("expected orphan to be carried through from the constructor argument")
+	}
+	if h.policy != dispatch.DeleteForeground {
+		t.Errorf("expected policy to be carried through from the constructor argument")
+	}
+}
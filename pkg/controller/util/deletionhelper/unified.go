@@ -0,0 +1,67 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deletionhelper
+
+import (
+	"k8s.io/apimachinery/pkg/api/meta"
+
+	fedv1b1 "sigs.k8s.io/kubefed/pkg/apis/core/v1beta1"
+	"sigs.k8s.io/kubefed/pkg/controller/sync/dispatch"
+)
+
+// cascadingDeletionPolicy maps a fedv1b1.DeletionPolicy to the
+// dispatch.CascadingDeletionPolicy NewUnmanagedDispatcher's Delete/
+// DeleteWithPolicy operations understand, defaulting unset values to
+// dispatch.DeleteBackground like the rest of the policy's zero value.
+func cascadingDeletionPolicy(policy fedv1b1.DeletionPolicy) dispatch.CascadingDeletionPolicy {
+	if policy == fedv1b1.DeletionPolicyForeground {
+		return dispatch.DeleteForeground
+	}
+	return dispatch.DeleteBackground
+}
+
+// NewDeletionHelperForFederatedObject builds the DeletionHelper that drives
+// cascading deletion for fedObject's member cluster copies, resolving the
+// underlying dispatcher through dispatch.NewUnifiedDispatcher and deriving
+// the orphan/cascading-policy settings from fedObject.Spec.PropagationPolicy.
+// It is the unified-dispatch counterpart of wiring a type-specific
+// UnmanagedDispatcher into NewDeletionHelper directly, letting a single
+// reconciler support every target kind the way NewUnifiedDispatcher already
+// does for plain deletes.
+func NewDeletionHelperForFederatedObject(restMapper meta.RESTMapper, informerForGVK dispatch.InformerForGVK, fedObject *fedv1b1.FederatedObject) (*DeletionHelper, error) {
+	dispatcher, err := dispatch.NewUnifiedDispatcher(restMapper, informerForGVK, fedObject)
+	if err != nil {
+		return nil, err
+	}
+	return newDeletionHelperForPropagationPolicy(dispatcher, fedObject.Spec.PropagationPolicy), nil
+}
+
+// NewDeletionHelperForClusterFederatedObject is the
+// NewDeletionHelperForFederatedObject counterpart for the cluster-scoped
+// ClusterFederatedObject, resolving the dispatcher through
+// dispatch.NewUnifiedDispatcherForClusterObject instead.
+func NewDeletionHelperForClusterFederatedObject(restMapper meta.RESTMapper, informerForGVK dispatch.InformerForGVK, fedObject *fedv1b1.ClusterFederatedObject) (*DeletionHelper, error) {
+	dispatcher, err := dispatch.NewUnifiedDispatcherForClusterObject(restMapper, informerForGVK, fedObject)
+	if err != nil {
+		return nil, err
+	}
+	return newDeletionHelperForPropagationPolicy(dispatcher, fedObject.Spec.PropagationPolicy), nil
+}
+
+func newDeletionHelperForPropagationPolicy(dispatcher dispatch.UnmanagedDispatcher, propagationPolicy fedv1b1.PropagationPolicy) *DeletionHelper {
+	return NewDeletionHelper(dispatcher, propagationPolicy.OrphanDependents, cascadingDeletionPolicy(propagationPolicy.DeletionPolicy))
+}
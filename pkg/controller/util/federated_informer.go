@@ -17,8 +17,9 @@ limitations under the License.
 package util
 
 import (
+	"hash/fnv"
 	"fmt"
-	"reflect"
+	"sort"
 	"sync"
 	"time"
 
@@ -42,6 +43,19 @@ const (
 	userAgentName     = "federation-controller"
 )
 
+// connectionAnnotationAllowlist lists the annotation keys that are
+// considered connection-affecting and therefore participate in
+// ClusterConnectionHash. Annotation changes outside of this allowlist
+// are ignored when deciding whether to recreate a cluster's target
+// informer. This API version has no dedicated Spec field for proxy
+// configuration, so a cluster fronted by a proxy is expected to carry it
+// as the "federation.kubernetes.io/proxy-url" annotation; including that
+// key here ensures a proxy change is still treated as connection-affecting.
+var connectionAnnotationAllowlist = []string{
+	"federation.kubernetes.io/connection-hash",
+	"federation.kubernetes.io/proxy-url",
+}
+
 // An object with an origin information.
 type FederatedObject struct {
 	Object      interface{}
@@ -103,6 +117,11 @@ type FederatedInformer interface {
 	// Returns a store created over all stores from target informers.
 	GetTargetStore() FederatedReadOnlyStore
 
+	// GetPodStore returns a typed, indexed overlay over the per-cluster Pod
+	// informers, or nil if this FederatedInformer was not built for the
+	// Pods resource (see NewFederatedInformer).
+	GetPodStore() FederatedPodStore
+
 	// Starts all the processes.
 	Start()
 
@@ -110,11 +129,17 @@ type FederatedInformer interface {
 	Stop()
 }
 
-// FederatedInformer with extra method for setting fake clients.
+// FederatedInformer with extra methods for setting fake clients and
+// overriding the ClusterConnectionHash annotation allowlist.
 type FederatedInformerForTestOnly interface {
 	FederatedInformer
 
 	SetClientFactory(func(*fedv1a1.FederatedCluster) (ResourceClient, error))
+
+	// SetConnectionAnnotationKeys overrides the allowlist of annotation
+	// keys ClusterConnectionHash hashes, in place of
+	// connectionAnnotationAllowlist.
+	SetConnectionAnnotationKeys(keys []string)
 }
 
 // A function that should be used to create an informer on the target object. Store should use
@@ -164,8 +189,25 @@ func NewFederatedInformer(
 			restclient.AddUserAgent(config, userAgentName)
 			return NewResourceClientFromConfig(config, apiResource)
 		},
-		targetInformers: make(map[string]informer),
-		fedNamespace:    fedNamespace,
+		targetInformers:          make(map[string]informer),
+		podInformers:             make(map[string]podInformer),
+		podNamespace:             targetNamespace,
+		fedNamespace:             fedNamespace,
+		connectionAnnotationKeys: connectionAnnotationAllowlist,
+	}
+
+	if apiResource.Kind == "Pod" {
+		federatedInformer.podClientFactory = func(cluster *fedv1a1.FederatedCluster) (kubeclientset.Interface, error) {
+			config, err := BuildClusterConfig(cluster, kubeClient, crClient, fedNamespace, clusterNamespace)
+			if err != nil {
+				return nil, err
+			}
+			if config == nil {
+				return nil, fmt.Errorf("Unable to load configuration for cluster %q", cluster.Name)
+			}
+			restclient.AddUserAgent(config, userAgentName)
+			return kubeclientset.NewForConfig(config)
+		}
 	}
 
 	getClusterData := func(name string) []interface{} {
@@ -227,7 +269,9 @@ func NewFederatedInformer(
 					glog.Errorf("Internal error: Cluster %v not updated.  New cluster not of correct type.", cur)
 					return
 				}
-				if IsClusterReady(oldCluster) != IsClusterReady(curCluster) || !reflect.DeepEqual(oldCluster.Spec, curCluster.Spec) || !reflect.DeepEqual(oldCluster.ObjectMeta.Annotations, curCluster.ObjectMeta.Annotations) {
+				readinessChanged := IsClusterReady(oldCluster) != IsClusterReady(curCluster)
+				connectionChanged := federatedInformer.connectionChanged(oldCluster, curCluster)
+				if readinessChanged || connectionChanged {
 					var data []interface{}
 					if clusterLifecycle.ClusterUnavailable != nil {
 						data = getClusterData(oldCluster.Name)
@@ -244,7 +288,7 @@ func NewFederatedInformer(
 						}
 					}
 				} else {
-					glog.V(4).Infof("Cluster %v not updated to %v as ready status and specs are identical", oldCluster, curCluster)
+					glog.V(4).Infof("Cluster %v not updated to %v: readiness and connection hash are unchanged", oldCluster, curCluster)
 				}
 			},
 		},
@@ -252,6 +296,39 @@ func NewFederatedInformer(
 	return federatedInformer
 }
 
+// ClusterConnectionHash returns a hash of the fields of cluster that affect
+// how a target informer connects to the cluster: the API endpoint, the CA
+// bundle, the secret backing the client credentials, and any annotations in
+// f.connectionAnnotationKeys (which is how proxy configuration is covered,
+// since this API version carries it as an annotation rather than a Spec
+// field). Two invocations return the same hash iff the cluster's
+// connection-relevant state is unchanged, so callers can use it to tell
+// benign edits (e.g. unrelated annotation churn) apart from changes that
+// require tearing down and recreating the target informer.
+func (f *federatedInformerImpl) ClusterConnectionHash(cluster *fedv1a1.FederatedCluster) string {
+	hasher := fnv.New32a()
+
+	fmt.Fprintf(hasher, "endpoint=%s;", cluster.Spec.APIEndpoint)
+	fmt.Fprintf(hasher, "caBundle=%x;", cluster.Spec.CABundle)
+	fmt.Fprintf(hasher, "secretRef=%s/%s;", cluster.Namespace, cluster.Spec.SecretRef.Name)
+
+	// Callers (addCluster, connectionChanged) already hold f.Lock, and
+	// SetConnectionAnnotationKeys takes it too, so connectionAnnotationKeys
+	// is read under the lock here without taking it again.
+	keys := make([]string, 0, len(f.connectionAnnotationKeys))
+	for _, key := range f.connectionAnnotationKeys {
+		if _, ok := cluster.ObjectMeta.Annotations[key]; ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Fprintf(hasher, "annotation[%s]=%s;", key, cluster.ObjectMeta.Annotations[key])
+	}
+
+	return fmt.Sprintf("%x", hasher.Sum32())
+}
+
 func IsClusterReady(cluster *fedv1a1.FederatedCluster) bool {
 	for _, condition := range cluster.Status.Conditions {
 		if condition.Type == fedcommon.ClusterReady {
@@ -267,6 +344,12 @@ type informer struct {
 	controller cache.Controller
 	store      cache.Store
 	stopChan   chan struct{}
+
+	// connectionHash is the ClusterConnectionHash computed for the
+	// cluster when this target informer was created. It is compared
+	// against the freshly computed hash on every cluster update to
+	// decide whether the informer needs to be recreated.
+	connectionHash string
 }
 
 type federatedInformerImpl struct {
@@ -281,11 +364,27 @@ type federatedInformerImpl struct {
 	// Structures returned by targetInformerFactory
 	targetInformers map[string]informer
 
+	// Typed, indexed Pod informers, one per cluster, populated only when
+	// this FederatedInformer was built for the Pods resource.
+	podInformers map[string]podInformer
+
+	// A function to build a typed client for a cluster's Pod informer. Nil
+	// unless this FederatedInformer was built for the Pods resource.
+	podClientFactory func(*fedv1a1.FederatedCluster) (kubeclientset.Interface, error)
+
+	// Namespace the Pod informers are scoped to.
+	podNamespace string
+
 	// A function to build clients.
 	clientFactory func(*fedv1a1.FederatedCluster) (ResourceClient, error)
 
 	// Namespace from which to source FederatedCluster resources
 	fedNamespace string
+
+	// Allowlist of annotation keys considered when computing
+	// ClusterConnectionHash. Defaults to connectionAnnotationAllowlist but
+	// may be overridden to accommodate deployment-specific annotations.
+	connectionAnnotationKeys []string
 }
 
 // *federatedInformerImpl implements FederatedInformer interface.
@@ -310,6 +409,11 @@ func (f *federatedInformerImpl) Stop() {
 		// an informer's stop channel.
 		delete(f.targetInformers, key)
 	}
+	for key, pi := range f.podInformers {
+		glog.V(4).Infof("... Closing pod informer channel for %q.", key)
+		close(pi.stopChan)
+		delete(f.podInformers, key)
+	}
 }
 
 func (f *federatedInformerImpl) Start() {
@@ -327,6 +431,18 @@ func (f *federatedInformerImpl) SetClientFactory(clientFactory func(*fedv1a1.Fed
 	f.clientFactory = clientFactory
 }
 
+// SetConnectionAnnotationKeys overrides the annotation allowlist consulted
+// by ClusterConnectionHash, replacing connectionAnnotationAllowlist. It
+// takes effect for connection hashes computed after it returns; clusters
+// added before the call keep the hash they were created with until their
+// next update triggers a recompute.
+func (f *federatedInformerImpl) SetConnectionAnnotationKeys(keys []string) {
+	f.Lock()
+	defer f.Unlock()
+
+	f.connectionAnnotationKeys = keys
+}
+
 // GetClientForCluster returns a client for the cluster, if present.
 func (f *federatedInformerImpl) GetClientForCluster(clusterName string) (ResourceClient, error) {
 	f.Lock()
@@ -414,6 +530,24 @@ func (f *federatedInformerImpl) ClustersSynced() bool {
 	return f.clusterInformer.controller.HasSynced()
 }
 
+// connectionChanged returns true if the target informer for oldCluster's
+// name was created from a connection hash that no longer matches
+// curCluster's. A cluster with no existing target informer (e.g. one that
+// has never been ready) has nothing to tear down, so it is treated as
+// unchanged here; readinessChanged already covers addCluster firing the
+// moment such a cluster actually becomes ready, and treating "no informer
+// yet" as changed would otherwise fire deleteCluster/ClusterUnavailable on
+// every resync of a persistently-not-ready cluster.
+func (f *federatedInformerImpl) connectionChanged(oldCluster, curCluster *fedv1a1.FederatedCluster) bool {
+	f.Lock()
+	defer f.Unlock()
+	targetInformer, found := f.targetInformers[oldCluster.Name]
+	if !found {
+		return false
+	}
+	return targetInformer.connectionHash != f.ClusterConnectionHash(curCluster)
+}
+
 // Adds the given cluster to federated informer.
 func (f *federatedInformerImpl) addCluster(cluster *fedv1a1.FederatedCluster) {
 	f.Lock()
@@ -422,12 +556,14 @@ func (f *federatedInformerImpl) addCluster(cluster *fedv1a1.FederatedCluster) {
 	if client, err := f.getClientForClusterUnlocked(name); err == nil {
 		store, controller := f.targetInformerFactory(cluster, client)
 		targetInformer := informer{
-			controller: controller,
-			store:      store,
-			stopChan:   make(chan struct{}),
+			controller:     controller,
+			store:          store,
+			stopChan:       make(chan struct{}),
+			connectionHash: f.ClusterConnectionHash(cluster),
 		}
 		f.targetInformers[name] = targetInformer
 		go targetInformer.controller.Run(targetInformer.stopChan)
+		f.addPodInformer(cluster)
 	} else {
 		// TODO: create also an event for cluster.
 		glog.Errorf("Failed to create a client for cluster: %v", err)
@@ -443,6 +579,7 @@ func (f *federatedInformerImpl) deleteCluster(cluster *fedv1a1.FederatedCluster)
 		close(targetInformer.stopChan)
 	}
 	delete(f.targetInformers, name)
+	f.deletePodInformer(cluster)
 }
 
 // Returns a store created over all stores from target informers.
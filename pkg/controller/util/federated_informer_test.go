@@ -0,0 +1,133 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"testing"
+
+	fedv1a1 "github.com/kubernetes-sigs/federation-v2/pkg/apis/core/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func newTestCluster(endpoint string, caBundle []byte, secretName string, annotations map[string]string) *fedv1a1.FederatedCluster {
+	return &fedv1a1.FederatedCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "test",
+			Namespace:   "federation-system",
+			Annotations: annotations,
+		},
+		Spec: fedv1a1.FederatedClusterSpec{
+			APIEndpoint: endpoint,
+			CABundle:    caBundle,
+			SecretRef:   fedv1a1.LocalSecretReference{Name: secretName},
+		},
+	}
+}
+
+func TestClusterConnectionHashIgnoresUnrelatedAnnotations(t *testing.T) {
+	f := &federatedInformerImpl{connectionAnnotationKeys: connectionAnnotationAllowlist}
+
+	before := newTestCluster("https://1.2.3.4", []byte("ca-data"), "creds", map[string]string{"unrelated": "a"})
+	after := newTestCluster("https://1.2.3.4", []byte("ca-data"), "creds", map[string]string{"unrelated": "b"})
+
+	if f.ClusterConnectionHash(before) != f.ClusterConnectionHash(after) {
+		t.Errorf("expected hash to be stable across changes to an annotation outside the allowlist")
+	}
+}
+
+func TestClusterConnectionHashChangesWithEndpoint(t *testing.T) {
+	f := &federatedInformerImpl{connectionAnnotationKeys: connectionAnnotationAllowlist}
+
+	before := newTestCluster("https://1.2.3.4", []byte("ca-data"), "creds", nil)
+	after := newTestCluster("https://5.6.7.8", []byte("ca-data"), "creds", nil)
+
+	if f.ClusterConnectionHash(before) == f.ClusterConnectionHash(after) {
+		t.Errorf("expected hash to change when APIEndpoint changes")
+	}
+}
+
+func TestClusterConnectionHashChangesWithCABundle(t *testing.T) {
+	f := &federatedInformerImpl{connectionAnnotationKeys: connectionAnnotationAllowlist}
+
+	before := newTestCluster("https://1.2.3.4", []byte("ca-data-1"), "creds", nil)
+	after := newTestCluster("https://1.2.3.4", []byte("ca-data-2"), "creds", nil)
+
+	if f.ClusterConnectionHash(before) == f.ClusterConnectionHash(after) {
+		t.Errorf("expected hash to change when CABundle changes")
+	}
+}
+
+func TestClusterConnectionHashChangesWithAllowlistedAnnotation(t *testing.T) {
+	f := &federatedInformerImpl{connectionAnnotationKeys: connectionAnnotationAllowlist}
+
+	before := newTestCluster("https://1.2.3.4", []byte("ca-data"), "creds", map[string]string{"federation.kubernetes.io/proxy-url": "http://proxy-a"})
+	after := newTestCluster("https://1.2.3.4", []byte("ca-data"), "creds", map[string]string{"federation.kubernetes.io/proxy-url": "http://proxy-b"})
+
+	if f.ClusterConnectionHash(before) == f.ClusterConnectionHash(after) {
+		t.Errorf("expected hash to change when an allowlisted annotation changes")
+	}
+}
+
+func TestConnectionChangedNoExistingInformer(t *testing.T) {
+	f := &federatedInformerImpl{
+		connectionAnnotationKeys: connectionAnnotationAllowlist,
+		targetInformers:          make(map[string]informer),
+	}
+
+	before := newTestCluster("https://1.2.3.4", []byte("ca-data"), "creds", nil)
+	after := newTestCluster("https://1.2.3.4", []byte("ca-data"), "creds", nil)
+
+	if f.connectionChanged(before, after) {
+		t.Errorf("expected connectionChanged to be false for a cluster with no existing target informer; readinessChanged alone drives addCluster for a newly-ready cluster")
+	}
+}
+
+func TestConnectionChangedExistingInformer(t *testing.T) {
+	before := newTestCluster("https://1.2.3.4", []byte("ca-data"), "creds", nil)
+	after := newTestCluster("https://5.6.7.8", []byte("ca-data"), "creds", nil)
+
+	f := &federatedInformerImpl{connectionAnnotationKeys: connectionAnnotationAllowlist}
+	f.targetInformers = map[string]informer{
+		before.Name: {connectionHash: f.ClusterConnectionHash(before)},
+	}
+
+	if !f.connectionChanged(before, after) {
+		t.Errorf("expected connectionChanged to be true when the existing target informer's hash no longer matches the current cluster")
+	}
+	if f.connectionChanged(before, before) {
+		t.Errorf("expected connectionChanged to be false when the current cluster's hash still matches the existing target informer")
+	}
+}
+
+func TestSetConnectionAnnotationKeysOverridesAllowlist(t *testing.T) {
+	f := &federatedInformerImpl{connectionAnnotationKeys: connectionAnnotationAllowlist}
+	f.SetConnectionAnnotationKeys([]string{"example.com/custom-key"})
+
+	before := newTestCluster("https://1.2.3.4", []byte("ca-data"), "creds", map[string]string{"example.com/custom-key": "a"})
+	after := newTestCluster("https://1.2.3.4", []byte("ca-data"), "creds", map[string]string{"example.com/custom-key": "b"})
+
+	if f.ClusterConnectionHash(before) == f.ClusterConnectionHash(after) {
+		t.Errorf("expected hash to change for a key configured via SetConnectionAnnotationKeys")
+	}
+
+	// The default allowlist's keys should no longer participate.
+	beforeDefault := newTestCluster("https://1.2.3.4", []byte("ca-data"), "creds", map[string]string{"federation.kubernetes.io/proxy-url": "http://proxy-a"})
+	afterDefault := newTestCluster("https://1.2.3.4", []byte("ca-data"), "creds", map[string]string{"federation.kubernetes.io/proxy-url": "http://proxy-b"})
+	if f.ClusterConnectionHash(beforeDefault) != f.ClusterConnectionHash(afterDefault) {
+		t.Errorf("expected default allowlist keys to be ignored once overridden")
+	}
+}
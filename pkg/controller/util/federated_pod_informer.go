@@ -0,0 +1,176 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"fmt"
+
+	apiv1 "k8s.io/api/core/v1"
+	apitypes "k8s.io/apimachinery/pkg/types"
+	coreinformers "k8s.io/client-go/informers/core/v1"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/golang/glog"
+
+	fedv1a1 "github.com/kubernetes-sigs/federation-v2/pkg/apis/core/v1alpha1"
+)
+
+const (
+	// indexNodeName indexes pods by spec.nodeName so GetPodsByNode is O(1).
+	indexNodeName = "spec.nodeName"
+	// indexOwnerUID indexes pods by the UID of each of their owner
+	// references so GetPodsByOwner is O(1).
+	indexOwnerUID = "ownerUID"
+	// indexPhase indexes pods by status.phase so GetPodsByPhase is O(1).
+	indexPhase = "status.phase"
+)
+
+// podInformerIndexers is shared by every per-cluster pod informer so that
+// GetPodsByNode/GetPodsByOwner behave identically regardless of cluster.
+var podInformerIndexers = cache.Indexers{
+	indexNodeName: func(obj interface{}) ([]string, error) {
+		pod, ok := obj.(*apiv1.Pod)
+		if !ok || pod.Spec.NodeName == "" {
+			return nil, nil
+		}
+		return []string{pod.Spec.NodeName}, nil
+	},
+	indexOwnerUID: func(obj interface{}) ([]string, error) {
+		pod, ok := obj.(*apiv1.Pod)
+		if !ok {
+			return nil, nil
+		}
+		uids := make([]string, 0, len(pod.OwnerReferences))
+		for _, ref := range pod.OwnerReferences {
+			uids = append(uids, string(ref.UID))
+		}
+		return uids, nil
+	},
+	indexPhase: func(obj interface{}) ([]string, error) {
+		pod, ok := obj.(*apiv1.Pod)
+		if !ok || pod.Status.Phase == "" {
+			return nil, nil
+		}
+		return []string{string(pod.Status.Phase)}, nil
+	},
+	cache.NamespaceIndex: cache.MetaNamespaceIndexFunc,
+}
+
+// FederatedPodStore is an overlay over the typed, indexed Pod informers
+// federatedInformerImpl maintains in parallel with its generic unstructured
+// target informers when it is configured for the Pods resource. It spares
+// consumers like the scheduler, automigration and status controllers from
+// re-listing and filtering unstructured pods themselves.
+type FederatedPodStore interface {
+	// GetPodsByNode returns the pods indexed against node in cluster.
+	GetPodsByNode(cluster, node string) ([]*apiv1.Pod, error)
+
+	// GetPodsByOwner returns the pods indexed against owner's UID in
+	// cluster.
+	GetPodsByOwner(cluster string, owner apitypes.UID) ([]*apiv1.Pod, error)
+
+	// GetPodsByPhase returns the pods indexed against phase in cluster.
+	GetPodsByPhase(cluster string, phase apiv1.PodPhase) ([]*apiv1.Pod, error)
+}
+
+// podInformer is the typed counterpart of informer, tracking the lifecycle
+// of a single cluster's Pod informer.
+type podInformer struct {
+	informer cache.SharedIndexInformer
+	stopChan chan struct{}
+}
+
+// federatedPodStoreImpl implements FederatedPodStore over
+// federatedInformerImpl.podInformers.
+type federatedPodStoreImpl struct {
+	federatedInformer *federatedInformerImpl
+}
+
+// GetPodStore returns a FederatedPodStore if f was built with an
+// apiResource of Pod (see NewFederatedInformer), or nil otherwise.
+func (f *federatedInformerImpl) GetPodStore() FederatedPodStore {
+	if f.podClientFactory == nil {
+		return nil
+	}
+	return &federatedPodStoreImpl{federatedInformer: f}
+}
+
+func (ps *federatedPodStoreImpl) GetPodsByNode(cluster, node string) ([]*apiv1.Pod, error) {
+	return ps.federatedInformer.getIndexedPods(cluster, indexNodeName, node)
+}
+
+func (ps *federatedPodStoreImpl) GetPodsByOwner(cluster string, owner apitypes.UID) ([]*apiv1.Pod, error) {
+	return ps.federatedInformer.getIndexedPods(cluster, indexOwnerUID, string(owner))
+}
+
+func (ps *federatedPodStoreImpl) GetPodsByPhase(cluster string, phase apiv1.PodPhase) ([]*apiv1.Pod, error) {
+	return ps.federatedInformer.getIndexedPods(cluster, indexPhase, string(phase))
+}
+
+func (f *federatedInformerImpl) getIndexedPods(cluster, indexName, indexValue string) ([]*apiv1.Pod, error) {
+	f.Lock()
+	pi, found := f.podInformers[cluster]
+	f.Unlock()
+	if !found {
+		return nil, fmt.Errorf("no pod informer for cluster %q", cluster)
+	}
+
+	objs, err := pi.informer.GetIndexer().ByIndex(indexName, indexValue)
+	if err != nil {
+		return nil, err
+	}
+	pods := make([]*apiv1.Pod, 0, len(objs))
+	for _, obj := range objs {
+		if pod, ok := obj.(*apiv1.Pod); ok {
+			pods = append(pods, pod)
+		}
+	}
+	return pods, nil
+}
+
+// addPodInformer builds and starts a typed, indexed Pod informer for
+// cluster if f was configured with a podClientFactory (i.e. apiResource was
+// Pod). It is called from addCluster while f's lock is held, mirroring how
+// the generic target informer is created.
+func (f *federatedInformerImpl) addPodInformer(cluster *fedv1a1.FederatedCluster) {
+	if f.podClientFactory == nil {
+		return
+	}
+
+	client, err := f.podClientFactory(cluster)
+	if err != nil {
+		glog.Errorf("Failed to create a typed client for cluster %q pod informer: %v", cluster.Name, err)
+		return
+	}
+
+	informer := coreinformers.NewFilteredPodInformer(client, f.podNamespace, clusterSyncPeriod, podInformerIndexers, nil)
+	pi := podInformer{
+		informer: informer,
+		stopChan: make(chan struct{}),
+	}
+	f.podInformers[cluster.Name] = pi
+	go pi.informer.Run(pi.stopChan)
+}
+
+// deletePodInformer stops and removes the pod informer for cluster, if any.
+// It is called from deleteCluster while f's lock is held.
+func (f *federatedInformerImpl) deletePodInformer(cluster *fedv1a1.FederatedCluster) {
+	if pi, found := f.podInformers[cluster.Name]; found {
+		close(pi.stopChan)
+		delete(f.podInformers, cluster.Name)
+	}
+}
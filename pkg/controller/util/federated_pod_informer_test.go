@@ -0,0 +1,143 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"testing"
+
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+)
+
+// newTestPodInformer builds a running, synced SharedIndexInformer over pods,
+// indexed the same way addPodInformer configures a real per-cluster pod
+// informer, so getIndexedPods can be exercised without a fake apiserver.
+func newTestPodInformer(t *testing.T, pods ...*apiv1.Pod) cache.SharedIndexInformer {
+	t.Helper()
+
+	items := make([]runtime.Object, 0, len(pods))
+	for _, pod := range pods {
+		items = append(items, pod)
+	}
+	listWatch := &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			list := &apiv1.PodList{}
+			for _, pod := range pods {
+				list.Items = append(list.Items, *pod)
+			}
+			return list, nil
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			return watch.NewFake(), nil
+		},
+	}
+
+	informer := cache.NewSharedIndexInformer(listWatch, &apiv1.Pod{}, 0, podInformerIndexers)
+	stopChan := make(chan struct{})
+	t.Cleanup(func() { close(stopChan) })
+	go informer.Run(stopChan)
+	if !cache.WaitForCacheSync(stopChan, informer.HasSynced) {
+		t.Fatalf("pod informer did not sync")
+	}
+	return informer
+}
+
+func TestGetIndexedPodsByNode(t *testing.T) {
+	scheduled := &apiv1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "scheduled", Namespace: "ns"},
+		Spec:       apiv1.PodSpec{NodeName: "node-a"},
+	}
+	unscheduled := &apiv1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "unscheduled", Namespace: "ns"},
+	}
+
+	f := &federatedInformerImpl{
+		podInformers: map[string]podInformer{
+			"cluster-a": {informer: newTestPodInformer(t, scheduled, unscheduled)},
+		},
+	}
+
+	got, err := f.getIndexedPods("cluster-a", indexNodeName, "node-a")
+	if err != nil {
+		t.Fatalf("getIndexedPods() returned error: %v", err)
+	}
+	if len(got) != 1 || got[0].Name != "scheduled" {
+		t.Errorf("expected only the pod scheduled onto node-a, got %+v", got)
+	}
+}
+
+func TestGetIndexedPodsByOwner(t *testing.T) {
+	owned := &apiv1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "owned", Namespace: "ns",
+			OwnerReferences: []metav1.OwnerReference{{UID: "owner-1"}},
+		},
+	}
+	unowned := &apiv1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "unowned", Namespace: "ns"},
+	}
+
+	ps := &federatedPodStoreImpl{federatedInformer: &federatedInformerImpl{
+		podInformers: map[string]podInformer{
+			"cluster-a": {informer: newTestPodInformer(t, owned, unowned)},
+		},
+	}}
+
+	got, err := ps.GetPodsByOwner("cluster-a", "owner-1")
+	if err != nil {
+		t.Fatalf("GetPodsByOwner() returned error: %v", err)
+	}
+	if len(got) != 1 || got[0].Name != "owned" {
+		t.Errorf("expected only the pod owned by owner-1, got %+v", got)
+	}
+}
+
+func TestGetIndexedPodsByPhase(t *testing.T) {
+	running := &apiv1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "running", Namespace: "ns"},
+		Status:     apiv1.PodStatus{Phase: apiv1.PodRunning},
+	}
+	pending := &apiv1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "pending", Namespace: "ns"},
+		Status:     apiv1.PodStatus{Phase: apiv1.PodPending},
+	}
+
+	ps := &federatedPodStoreImpl{federatedInformer: &federatedInformerImpl{
+		podInformers: map[string]podInformer{
+			"cluster-a": {informer: newTestPodInformer(t, running, pending)},
+		},
+	}}
+
+	got, err := ps.GetPodsByPhase("cluster-a", apiv1.PodPending)
+	if err != nil {
+		t.Fatalf("GetPodsByPhase() returned error: %v", err)
+	}
+	if len(got) != 1 || got[0].Name != "pending" {
+		t.Errorf("expected only the pending pod, got %+v", got)
+	}
+}
+
+func TestGetIndexedPodsUnknownCluster(t *testing.T) {
+	f := &federatedInformerImpl{podInformers: map[string]podInformer{}}
+
+	if _, err := f.getIndexedPods("missing", indexNodeName, "node-a"); err == nil {
+		t.Errorf("expected an error for a cluster with no pod informer")
+	}
+}